@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"time"
+	"trk/internal/lib/provider/ice/fakeportal"
+	"trk/internal/lib/provider/oebb/fakerailnet"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8080", "address to listen on")
+	scenarioPath := flag.String("scenario", "internal/lib/provider/ice/fakeportal/testdata/bamberg-muenchen.json", "scenario file to play")
+	speed := flag.Float64("speed", 1, "playback speed, 1 is real time")
+	seek := flag.Duration("seek", 0, "start the scenario at this offset")
+	railnetDir := flag.String("railnet", "", "serve this railnet.oebb.at recording instead of the ICE scenario, e.g. internal/lib/provider/oebb/fakerailnet/testdata/salzburg-wien")
+	interval := flag.Duration("interval", 30*time.Second, "time between the snapshots of --railnet")
+	flag.Parse()
+
+	if *railnetDir != "" {
+		snapshots, err := fakerailnet.LoadSnapshots(*railnetDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		railnet := fakerailnet.NewRailnet(snapshots)
+		railnet.Play(*interval)
+		log.Printf("serving %s on http://%s, run trk with --oebb-url http://%s\n", *railnetDir, *addr, *addr)
+		log.Fatal(http.ListenAndServe(*addr, railnet))
+	}
+
+	scenario, err := fakeportal.LoadScenario(*scenarioPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	portal := fakeportal.NewPortal(scenario)
+	portal.Seek(*seek)
+	portal.Play(*speed)
+
+	log.Printf("serving %s on http://%s, run trk with --ice-url http://%s\n", *scenarioPath, *addr, *addr)
+	log.Fatal(http.ListenAndServe(*addr, portal))
+}
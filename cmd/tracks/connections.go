@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"trk/internal/lib/helper"
+	"trk/internal/lib/provider"
+	"trk/internal/lib/transfer"
+	"trk/internal/lib/types"
+)
+
+// connectionRefresh is how often the onward connections at the destination are fetched
+const connectionRefresh = time.Minute
+
+var transferMonitor *transfer.Monitor
+var transferPin *transfer.Pin
+
+// connectionState holds the onward connections at the destination
+var connectionState struct {
+	sync.Mutex
+	stopId      string
+	fetched     time.Time
+	fetching    bool
+	connections []types.Connection
+}
+
+// watchConnections refreshes the onward connections at the destination in the background
+// and warns about those which can't be reached anymore with the current arrival time
+func watchConnections(stop *types.Stop) {
+	connectionProvider, ok := currentProvider.(provider.ConnectionProvider)
+	connectionState.Lock()
+	if stop == nil || !ok {
+		cleared := connectionState.stopId != ""
+		connectionState.stopId = ""
+		connectionState.connections = nil
+		connectionState.Unlock()
+		if cleared {
+			ui.updateConnections(nil, nil)
+		}
+		return
+	}
+	if stop.Id != connectionState.stopId {
+		connectionState.stopId = stop.Id
+		connectionState.connections = nil
+		connectionState.fetched = time.Time{}
+	}
+	if !connectionState.fetching && time.Since(connectionState.fetched) >= connectionRefresh {
+		connectionState.fetching = true
+		go fetchConnections(connectionProvider, *stop)
+	}
+	connections := connectionState.connections
+	connectionState.Unlock()
+	checkConnections(*stop, connections)
+}
+
+func fetchConnections(connectionProvider provider.ConnectionProvider, stop types.Stop) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	connections, err := connectionProvider.GetConnections(ctx, stop.Id)
+
+	connectionState.Lock()
+	connectionState.fetching = false
+	connectionState.fetched = time.Now()
+	if err != nil {
+		connectionState.Unlock()
+		log.Printf("Error fetching connections at %s: %s\n", stop.Name, err.Error())
+		return
+	}
+	if stop.Id != connectionState.stopId {
+		connectionState.Unlock()
+		return
+	}
+	connectionState.connections = connections
+	connectionState.Unlock()
+
+	checkConnections(stop, connections)
+	ui.updateConnections(&stop, connections)
+}
+
+// checkConnections notifies about every connection which just became unreachable
+// and about the transfer buffer of the pinned connection
+func checkConnections(stop types.Stop, connections []types.Connection) {
+	missed := transferMonitor.Update(stop, connections)
+	for _, connection := range missed {
+		// the pinned connection is announced together with its alternative
+		if !transferPin.IsPinned(stop.Id, connection) {
+			notifications.NotifyMissedConnection(stop, connection)
+		}
+	}
+	if risk, ok := transferPin.Check(stop, connections, transferMonitor.MinTransfer); ok {
+		notifications.NotifyTransfer(risk)
+	}
+	if len(missed) > 0 {
+		ui.updateConnections(&stop, connections)
+	}
+}
+
+// pinConnection pins connection at stop, or unpins it if it is already pinned
+func pinConnection(stop types.Stop, connection types.Connection) {
+	if transferPin.IsPinned(stop.Id, connection) {
+		transferPin.Clear()
+	} else {
+		transferPin.Set(stop.Id, connection)
+	}
+	connectionState.Lock()
+	connections := connectionState.connections
+	connectionState.Unlock()
+	checkConnections(stop, connections)
+	ui.updateConnections(&stop, connections)
+}
+
+// transferText returns e.g. "Transfer to RE 5 → Koblenz Hbf 15:04 (+1) track 4: 6 min"
+// for the pinned connection, or the header of the connection list if nothing is pinned
+func transferText(stop types.Stop, connections []types.Connection) string {
+	connection, ok := transferPin.Find(stop, connections)
+	if !ok {
+		return fmt.Sprintf("Connections at %s", stop.Name)
+	}
+	text := fmt.Sprintf("Transfer to %s: %d min", helper.FormatConnection(connection),
+		int(transfer.Buffer(stop.ArrivalTime, connection).Minutes()))
+	if !transferMonitor.Reachable(stop, connection) {
+		text = "✗ " + text
+	}
+	return text
+}
@@ -1,93 +1,142 @@
 package main
 
 import (
-	"fmt"
-	"github.com/gotk3/gotk3/glib"
+	"log"
+	"sync"
 	"time"
-	"trk/internal/lib/helper"
+	"trk/internal/lib/events"
+	"trk/internal/lib/tracker"
 	"trk/internal/lib/types"
 )
 
 var statusChan chan types.Status
+var eventEngine *events.Engine
+var notifications *tracker.Tracker
 
 func init() {
 	statusChan = make(chan types.Status)
+	eventEngine = events.NewEngine()
+}
+
+// hasEvent returns true if changes contain an event of one of the types
+func hasEvent(changes []events.Event, eventTypes ...events.Type) bool {
+	for _, event := range changes {
+		for _, t := range eventTypes {
+			if event.Type == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// destination is the stop selected in the menu, it is set from the GTK and the event goroutine
+var destination struct {
+	sync.Mutex
+	stop *types.Stop
+}
+
+// getDestination returns the selected destination, nil if there is none
+func getDestination() *types.Stop {
+	destination.Lock()
+	defer destination.Unlock()
+	return destination.stop
+}
+
+// setDestination selects the stop for the destination label and the arrival alarm, nil clears it
+func setDestination(stop *types.Stop) {
+	destination.Lock()
+	defer destination.Unlock()
+	destination.stop = stop
+	stopId := ""
+	if stop != nil {
+		stopId = stop.Id
+	}
+	eventEngine.SetDestination(stopId)
+	arrivalAlarm.SetDestination(stopId)
+	if transferPin.StopId() != stopId {
+		transferPin.Clear()
+	}
+}
+
+// checkArrival sends the arrival reminders and clears the destination once we passed it
+func checkArrival(status types.Status, changes []events.Event) {
+	for _, event := range changes {
+		if event.Type == events.StopPassed && event.Destination {
+			setDestination(nil)
+			return
+		}
+	}
+	stopId := arrivalAlarm.Destination()
+	if stopId == "" {
+		return
+	}
+	stop := currentTrip.GetStop(stopId)
+	if stop == nil {
+		return
+	}
+	now := status.Timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if reminder, ok := arrivalAlarm.Check(now, *stop); ok {
+		notifications.NotifyArrival(reminder)
+	}
+}
+
+// updateMetered marks the WiFi as metered while the internet is weak
+func updateMetered(connectivity types.Connectivity) {
+	if connectivityMeter == nil {
+		return
+	}
+	if err := connectivityMeter.Update(connectivity); err != nil {
+		log.Printf("Error setting metered connection: %s\n", err.Error())
+	}
+}
+
+// restoreMetered resets the metered setting changed by updateMetered
+func restoreMetered() {
+	if connectivityMeter == nil {
+		return
+	}
+	if err := connectivityMeter.Restore(); err != nil {
+		log.Printf("Error restoring metered connection: %s\n", err.Error())
+	}
 }
 
 func eventHandler() {
-	lastNextStopId := ""
-	var lastDelay time.Duration
+	eventChan, unsubscribe := eventEngine.Subscribe()
+	defer unsubscribe()
+	notifications = tracker.NewTracker(ui)
+	go notifications.Run(eventChan)
+
 	for status := range statusChan {
 		if currentProvider == nil {
 			print("Current provider is missing")
 			continue
 		}
 		currentTrip = currentProvider.GetStops()
-
-		glib.IdleAdd(func() {
-			if currentTrain == nil || status.Train.Id != currentTrain.Id {
-				currentTrain = &status.Train
-				sendNotification(
-					"New Train", fmt.Sprintf("Welcome to <b>%s</b> (It's %s %s)",
-						status.Train.DisplayName,
-						helper.GetIndefiniteArticle(status.Train.SeriesDisplay),
-						status.Train.SeriesDisplay,
-					), helper.TrainNotification, &status.Train)
-				labelTrain.SetLabel("Train: " + status.Train.DisplayName)
-				buildTransferMenu(currentTrip)
-			}
-			if status.NextStop.Id != lastNextStopId {
-				lastNextStopId = status.NextStop.Id
-				sendNotification(
-					"Next Stop",
-					fmt.Sprintf(
-						"<b>%s</b> at %s",
-						status.NextStop.Name,
-						status.NextStop.ArrivalTime,
-					),
-					helper.StationNotification,
-					status.NextStop.Location,
-				)
-			}
-			if status.Delay > time.Minute*2 {
-				if status.Delay != lastDelay {
-					lastDelay = status.Delay
-					sendNotification(
-						"Delay",
-						fmt.Sprintf("Current Delay is %s", status.Delay),
-						helper.DelayNotification,
-						nil,
-					)
-				}
+		if gpxRecorder != nil {
+			if err := gpxRecorder.Record(status); err != nil {
+				log.Printf("Error recording gpx: %s\n", err.Error())
 			}
+		}
 
-			labelSpeed.SetLabel(fmt.Sprintf("Speed: %d km/h", int64(float64(status.Speed)/3.6)))
+		updateMetered(status.Connectivity)
 
-			labelNextStop.SetLabel(fmt.Sprintf("Next Stop: %s %s",
-				status.NextStop.Name,
-				status.NextStop.ArrivalTime.Sub(time.Now()).Truncate(time.Second*1).String()))
-			if destinationStop != nil {
-				labelDestination.Show()
-				if s := currentTrip.GetStop(destinationStop.Id); s != nil {
-					dstStr := fmt.Sprintf("Destination: %s in %s", s.Name, s.ArrivalTime.Sub(time.Now()).Truncate(1*time.Second))
-					labelDestination.SetLabel(dstStr)
-				} else {
-					labelDestination.SetLabel("Destination: Error")
-				}
-			}
-			if status.Delay > time.Minute*5 {
-				indicator.SetLabel(fmt.Sprintf("!Delay: +%s ", status.Delay), "")
-			} else if status.NextStop.ArrivalTime.Sub(time.Now()) < time.Minute*10 {
-				duration := "now"
-				if status.NextStop.ArrivalTime.Sub(time.Now()).Minutes() > 0 {
-					duration = fmt.Sprintf("in %s", status.NextStop.ArrivalTime.Sub(time.Now()).Truncate(1*time.Second))
-				}
-				indicator.SetLabel(fmt.Sprintf("Next Stop: %s %s", status.NextStop.Name, duration), "")
-			} else if status.Speed > 0 {
-				indicator.SetLabel(fmt.Sprintf("%d km/h", int64(float64(status.Speed)/3.6)), "")
-			} else {
-				indicator.SetLabel(currentTrain.DisplayName, "")
-			}
-		})
+		changes := eventEngine.Feed(status, currentTrip)
+		if hasEvent(changes, events.TrainChanged) {
+			train := status.Train
+			currentTrain = &train
+			// the destination was a stop of the previous train
+			setDestination(nil)
+		}
+		checkArrival(status, changes)
+		if stop := getDestination(); stop != nil {
+			watchConnections(currentTrip.GetStop(stop.Id))
+		} else {
+			watchConnections(nil)
+		}
+		ui.update(status, changes)
 	}
 }
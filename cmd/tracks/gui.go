@@ -0,0 +1,301 @@
+//go:build !headless
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/esiqveland/notify"
+	"github.com/gopherlibs/appindicator/appindicator"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"log"
+	"time"
+	"trk/internal/lib/events"
+	"trk/internal/lib/external"
+	"trk/internal/lib/files"
+	"trk/internal/lib/helper"
+	"trk/internal/lib/types"
+)
+
+const TextDestinationSelect = "Select Destination"
+
+var StationActions = []notify.Action{
+	{Key: "show-station", Label: "Show on Map"},
+}
+
+var AlarmActions = []notify.Action{
+	{Key: "snooze", Label: "Snooze"},
+}
+
+var TrainActions = []notify.Action{
+	{Key: "train", Label: "Show on Vagonweb.cz"},
+}
+
+// UI Variables
+var (
+	labelTrain       *gtk.MenuItem
+	labelSpeed       *gtk.MenuItem
+	labelInternet    *gtk.MenuItem
+	labelNextStop    *gtk.MenuItem
+	labelDestination *gtk.MenuItem
+	labelConnections *gtk.MenuItem
+	labelIdle        *gtk.MenuItem
+	indicator        *appindicator.Indicator
+	notifyStore      *helper.NotifyStore
+)
+
+// gui is the frontend showing the ride in the tray and as desktop notifications
+type gui struct{}
+
+func runGUI(ctx context.Context, cancel context.CancelFunc) {
+	ui = &gui{}
+	notifyStore = &helper.NotifyStore{}
+
+	initNotifier()
+	defer cleanupNotifier()
+	gtk.Init(nil)
+	defer files.CleanUp()
+
+	var err error
+
+	item, err := gtk.MenuItemNewWithLabel("Quit")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	menu, err := gtk.MenuNew()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, mi := range createLabels() {
+		menu.Add(mi)
+	}
+
+	_ = item.Connect("activate", func() {
+		cancel()
+		gtk.MainQuit()
+	})
+
+	menu.Add(item)
+	item.Show()
+	menu.ShowAll()
+
+	indicator = appindicator.New("train-tracker", "", appindicator.CategoryOther)
+
+	indicator.SetMenu(menu)
+	indicator.SetLabel("Trk", "guide")
+	indicator.SetStatus(appindicator.StatusActive)
+	indicator.SetIconFull(files.GetIconPath("images/trk.png"), "Icon")
+	providersDone := run(ctx)
+
+	gtk.Main()
+	cancel()
+	<-providersDone
+}
+
+func (g *gui) Notify(summary, body string, t helper.NotificationType, data any) {
+	sendNotification(summary, body, t, data)
+}
+
+func (g *gui) setIdle() {
+	guiSetIdle()
+}
+
+func (g *gui) setActive() {
+	guiSetActive()
+}
+
+func (g *gui) update(status types.Status, changes []events.Event) {
+	trip := currentTrip
+	trainChanged := hasEvent(changes, events.TrainChanged)
+	stopsChanged := hasEvent(changes, events.TrackChanged, events.StopPassed, events.StopCancelled)
+	connectionState.Lock()
+	connections := connectionState.connections
+	connectionState.Unlock()
+	destinationStop := getDestination()
+	glib.IdleAdd(func() {
+		if trainChanged {
+			name := status.Train.DisplayName
+			if name == "" {
+				name = "unknown"
+			}
+			labelTrain.SetLabel("Train: " + name)
+		}
+		if trainChanged || stopsChanged {
+			buildTransferMenu(trip)
+		}
+
+		labelSpeed.SetLabel(fmt.Sprintf("Speed: %d km/h", status.SpeedKmh()))
+		labelInternet.SetLabel("Internet: " + helper.FormatConnectivity(status.Connectivity))
+
+		labelNextStop.SetLabel(fmt.Sprintf("Next Stop: %s %s (in %s)",
+			status.NextStop.Name,
+			helper.FormatTimetable(status.NextStop.ArrivalTime, status.NextStop.ScheduledArrivalTime),
+			status.NextStop.ArrivalTime.Sub(time.Now()).Truncate(time.Second*1).String()))
+		if destinationStop != nil {
+			labelDestination.Show()
+			if s := trip.GetStop(destinationStop.Id); s != nil {
+				dstStr := fmt.Sprintf("Destination: %s in %s", s.Name, s.ArrivalTime.Sub(time.Now()).Truncate(1*time.Second))
+				labelDestination.SetLabel(dstStr)
+				if len(connections) > 0 {
+					labelConnections.SetLabel(transferText(*s, connections))
+				}
+			} else {
+				labelDestination.SetLabel("Destination: Error")
+			}
+		} else {
+			labelDestination.SetLabel(TextDestinationSelect)
+		}
+		if status.Delay > time.Minute*5 {
+			indicator.SetLabel(fmt.Sprintf("!Delay: +%s ", status.Delay), "")
+		} else if !status.NextStop.ArrivalTime.IsZero() && status.NextStop.ArrivalTime.Sub(time.Now()) < time.Minute*10 {
+			duration := "now"
+			if status.NextStop.ArrivalTime.Sub(time.Now()).Minutes() > 0 {
+				duration = fmt.Sprintf("in %s", status.NextStop.ArrivalTime.Sub(time.Now()).Truncate(1*time.Second))
+			}
+			indicator.SetLabel(fmt.Sprintf("Next Stop: %s %s", status.NextStop.Name, duration), "")
+		} else if status.Speed > 0 {
+			indicator.SetLabel(fmt.Sprintf("%d km/h", status.SpeedKmh()), "")
+		} else {
+			indicator.SetLabel(status.Train.DisplayName, "")
+		}
+	})
+}
+
+func (g *gui) updateConnections(stop *types.Stop, connections []types.Connection) {
+	glib.IdleAdd(func() {
+		if stop == nil || len(connections) == 0 {
+			labelConnections.Hide()
+			return
+		}
+		labelConnections.SetLabel(transferText(*stop, connections))
+		buildConnectionMenu(*stop, connections)
+		labelConnections.Show()
+	})
+}
+
+func createLabels() []*gtk.MenuItem {
+	labelIdle = mustCreateMenuItemWithLabel("Waiting for Train", nil)
+	labelNextStop = mustCreateMenuItemWithLabel("next", func() {
+		if currentTrip != nil {
+			if nextStop := currentTrip.GetNextStop(); nextStop != nil {
+				external.OpenOEPNVKarte(nextStop.Location)
+			}
+		}
+	})
+	labelDestination = mustCreateMenuItemWithLabel(TextDestinationSelect, nil)
+	labelConnections = mustCreateMenuItemWithLabel("Connections", nil)
+	labelTrain = mustCreateMenuItemWithLabel("train", func() {
+		if currentTrain != nil {
+			external.OpenVagonWeb(currentTrain)
+		}
+	})
+	labelSpeed = mustCreateMenuItemWithLabel("speed", nil)
+	labelInternet = mustCreateMenuItemWithLabel("internet", nil)
+	return []*gtk.MenuItem{
+		labelIdle,
+		labelTrain,
+		labelNextStop,
+		labelDestination,
+		labelConnections,
+		labelSpeed,
+		labelInternet,
+	}
+}
+
+func mustCreateMenuItemWithLabel(text string, onActivate func()) *gtk.MenuItem {
+	mi, err := gtk.MenuItemNew()
+	if err != nil {
+		panic(err)
+	}
+	mi.SetLabel(text)
+	if onActivate != nil {
+		mi.Connect("activate", onActivate)
+	}
+	return mi
+}
+
+func buildTransferMenu(stops []types.Stop) {
+	menu, err := gtk.MenuNew()
+	if err != nil {
+		panic(err)
+	}
+	for _, stop := range stops {
+		mi, err := gtk.MenuItemNew()
+		if err != nil {
+			panic(err)
+		}
+
+		arrivalStr := ""
+		if timetable := helper.FormatTimetable(stop.ArrivalTime, stop.ScheduledArrivalTime); timetable != "" {
+			arrivalStr = fmt.Sprintf(" [%s]", timetable)
+		} else if timetable = helper.FormatTimetable(stop.DepartureTime, stop.ScheduledDepartureTime); timetable != "" {
+			arrivalStr = fmt.Sprintf(" [dep. %s]", timetable)
+		}
+		track := stop.Track
+		if stop.TrackChanged() {
+			track = fmt.Sprintf("! %s instead of %s", stop.Track, stop.ScheduledTrack)
+		}
+		labelText := fmt.Sprintf("%s (%s)%s", stop.Name, track, arrivalStr)
+		mi.SetLabel(labelText)
+		stopId := stop.Id // just a local copy
+		_ = mi.Connect("activate", func(obj *gtk.MenuItem) {
+			if stop := currentTrip.GetStop(stopId); stop != nil {
+				setDestination(stop)
+			}
+
+		})
+		menu.Add(mi)
+	}
+	labelDestination.SetSubmenu(menu)
+	menu.ShowAll()
+}
+
+// buildConnectionMenu lists the onward connections at stop, unreachable ones are marked with ✗.
+// Activating a connection pins it.
+func buildConnectionMenu(stop types.Stop, connections []types.Connection) {
+	menu, err := gtk.MenuNew()
+	if err != nil {
+		panic(err)
+	}
+	for _, connection := range connections {
+		labelText := helper.FormatConnection(connection)
+		if !transferMonitor.Reachable(stop, connection) {
+			labelText = "✗ " + labelText
+		}
+		if transferPin.IsPinned(stop.Id, connection) {
+			labelText = "📌 " + labelText
+		}
+		connection := connection // just a local copy
+		menu.Add(mustCreateMenuItemWithLabel(labelText, func() {
+			go pinConnection(stop, connection)
+		}))
+	}
+	labelConnections.SetSubmenu(menu)
+	menu.ShowAll()
+}
+
+func guiSetActive() {
+	glib.IdleAdd(func() {
+		labelIdle.Hide()
+		labelTrain.Show()
+		labelDestination.Show()
+		labelSpeed.Show()
+		labelInternet.Show()
+		labelNextStop.Show()
+	})
+
+}
+
+func guiSetIdle() {
+	glib.IdleAdd(func() {
+		labelIdle.Show()
+		labelTrain.Hide()
+		labelDestination.Hide()
+		labelConnections.Hide()
+		labelSpeed.Hide()
+		labelInternet.Hide()
+		labelNextStop.Hide()
+	})
+}
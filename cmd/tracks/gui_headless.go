@@ -0,0 +1,14 @@
+//go:build headless
+
+package main
+
+import (
+	"context"
+	"log"
+)
+
+// runGUI is not available in a build with the headless tag, it neither links GTK nor
+// talks to the notification daemon
+func runGUI(ctx context.Context, cancel context.CancelFunc) {
+	log.Fatal("trk was built without the tray icon, run it with --headless")
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"trk/internal/lib/events"
+	"trk/internal/lib/helper"
+	"trk/internal/lib/types"
+)
+
+var markupTags = regexp.MustCompile(`<[^>]+>`)
+
+// headless is the frontend printing the ride to a terminal, either human-readable or as JSON lines
+type headless struct {
+	mu       sync.Mutex
+	out      io.Writer
+	json     bool
+	lastLine string
+	// lastConnections is the last printed list of connections
+	lastConnections string
+}
+
+type headlessLine struct {
+	Type         string               `json:"type"`
+	Time         time.Time            `json:"time"`
+	Summary      string               `json:"summary,omitempty"`
+	Body         string               `json:"body,omitempty"`
+	Status       *headlessStatus      `json:"status,omitempty"`
+	TrainChanged bool                 `json:"trainChanged,omitempty"`
+	Stop         string               `json:"stop,omitempty"`
+	Connections  []headlessConnection `json:"connections,omitempty"`
+}
+
+type headlessConnection struct {
+	Train                  string    `json:"train"`
+	Destination            string    `json:"destination"`
+	DepartureTime          time.Time `json:"departureTime"`
+	ScheduledDepartureTime time.Time `json:"scheduledDepartureTime"`
+	Track                  string    `json:"track"`
+	Cancelled              bool      `json:"cancelled"`
+	Reachable              bool      `json:"reachable"`
+}
+
+type headlessStatus struct {
+	Train        types.Train    `json:"train"`
+	SpeedKmh     int64          `json:"speedKmh"`
+	Timestamp    time.Time      `json:"timestamp"`
+	DelaySeconds int64          `json:"delaySeconds"`
+	Location     types.Location `json:"location"`
+	NextStop     struct {
+		Id                   string    `json:"id"`
+		Name                 string    `json:"name"`
+		ArrivalTime          time.Time `json:"arrivalTime"`
+		ScheduledArrivalTime time.Time `json:"scheduledArrivalTime"`
+		Track                string    `json:"track"`
+	} `json:"nextStop"`
+	Connectivity struct {
+		CurrentState  string `json:"currentState"`
+		NextState     string `json:"nextState"`
+		NextInSeconds int64  `json:"nextInSeconds"`
+	} `json:"connectivity"`
+}
+
+func newHeadless(out io.Writer, format string) (*headless, error) {
+	switch format {
+	case "text":
+		return &headless{out: out}, nil
+	case "json":
+		return &headless{out: out, json: true}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q, use text or json", format)
+}
+
+// runHeadless runs the providers until ctx is cancelled or trk is interrupted
+func runHeadless(ctx context.Context) {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-run(ctx)
+}
+
+func (h *headless) Notify(summary, body string, t helper.NotificationType, data any) {
+	body = markupTags.ReplaceAllString(body, "")
+	if h.json {
+		h.writeJSON(headlessLine{Type: "notification", Time: time.Now(), Summary: summary, Body: body})
+		return
+	}
+	h.writeText(fmt.Sprintf("[%s] %s", summary, body))
+}
+
+func (h *headless) setIdle() {
+	if h.json {
+		h.writeJSON(headlessLine{Type: "idle", Time: time.Now()})
+		return
+	}
+	h.writeText("Waiting for Train")
+}
+
+func (h *headless) setActive() {
+	if h.json {
+		h.writeJSON(headlessLine{Type: "active", Time: time.Now()})
+	}
+}
+
+func (h *headless) update(status types.Status, changes []events.Event) {
+	if h.json {
+		s := &headlessStatus{
+			Train:        status.Train,
+			SpeedKmh:     status.SpeedKmh(),
+			Timestamp:    status.Timestamp,
+			DelaySeconds: int64(status.Delay.Seconds()),
+			Location:     status.Location,
+		}
+		s.NextStop.Id = status.NextStop.Id
+		s.NextStop.Name = status.NextStop.Name
+		s.NextStop.ArrivalTime = status.NextStop.ArrivalTime
+		s.NextStop.ScheduledArrivalTime = status.NextStop.ScheduledArrivalTime
+		s.NextStop.Track = status.NextStop.Track
+		s.Connectivity.CurrentState = status.Connectivity.CurrentState
+		s.Connectivity.NextState = status.Connectivity.NextState
+		s.Connectivity.NextInSeconds = int64(status.Connectivity.NextIn.Seconds())
+		h.writeJSON(headlessLine{Type: "status", Time: time.Now(), Status: s, TrainChanged: hasEvent(changes, events.TrainChanged)})
+		return
+	}
+
+	name := status.Train.DisplayName
+	if name == "" {
+		name = "Unknown train"
+	}
+	line := fmt.Sprintf("%s | %d km/h", name, status.SpeedKmh())
+	if status.NextStop.Name != "" {
+		line += fmt.Sprintf(" | Next Stop: %s %s",
+			status.NextStop.Name,
+			helper.FormatTimetable(status.NextStop.ArrivalTime, status.NextStop.ScheduledArrivalTime),
+		)
+	}
+	if status.Delay > 0 {
+		line += fmt.Sprintf(" | Delay: +%s", status.Delay)
+	}
+	if state := status.Connectivity.CurrentState; state != "" && state != types.ConnectivityHigh {
+		line += " | Internet: " + helper.ConnectivityName(state)
+	}
+	h.writeText(line)
+}
+
+func (h *headless) updateConnections(stop *types.Stop, connections []types.Connection) {
+	if stop == nil || len(connections) == 0 {
+		return
+	}
+	lines := make([]string, 0, len(connections))
+	list := make([]headlessConnection, 0, len(connections))
+	for _, connection := range connections {
+		reachable := transferMonitor.Reachable(*stop, connection)
+		line := helper.FormatConnection(connection)
+		if !reachable {
+			line = "✗ " + line
+		}
+		lines = append(lines, line)
+		list = append(list, headlessConnection{
+			Train:                  connection.Train.DisplayName,
+			Destination:            connection.Destination,
+			DepartureTime:          connection.DepartureTime,
+			ScheduledDepartureTime: connection.ScheduledDepartureTime,
+			Track:                  connection.Track,
+			Cancelled:              connection.Cancelled,
+			Reachable:              reachable,
+		})
+	}
+	text := fmt.Sprintf("Connections at %s: %s", stop.Name, strings.Join(lines, ", "))
+	h.mu.Lock()
+	if text == h.lastConnections {
+		h.mu.Unlock()
+		return
+	}
+	h.lastConnections = text
+	h.mu.Unlock()
+
+	if h.json {
+		h.writeJSON(headlessLine{Type: "connections", Time: time.Now(), Stop: stop.Name, Connections: list})
+		return
+	}
+	h.writeText(text)
+}
+
+// writeText prints line unless it is the same as the previous one
+func (h *headless) writeText(line string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if line == h.lastLine {
+		return
+	}
+	h.lastLine = line
+	fmt.Fprintf(h.out, "%s %s\n", time.Now().Format("15:04:05"), line)
+}
+
+func (h *headless) writeJSON(line headlessLine) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := json.NewEncoder(h.out).Encode(line); err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing status:", err.Error())
+	}
+}
@@ -1,172 +1,233 @@
 package main
 
 import (
-	"fmt"
-	"github.com/esiqveland/notify"
-	"github.com/gopherlibs/appindicator/appindicator"
-	"github.com/gotk3/gotk3/glib"
-	"github.com/gotk3/gotk3/gtk"
+	"context"
+	"flag"
 	"log"
-	"trk/internal/lib/external"
-	"trk/internal/lib/files"
+	"os"
+	"strings"
+	"time"
+	"trk/internal/lib/alarm"
+	"trk/internal/lib/events"
+	"trk/internal/lib/gtfs"
 	"trk/internal/lib/helper"
+	"trk/internal/lib/networkmanager"
+	"trk/internal/lib/provider"
+	"trk/internal/lib/provider/cd"
+	"trk/internal/lib/provider/geoclue"
+	"trk/internal/lib/provider/gpsd"
+	"trk/internal/lib/provider/ice"
+	"trk/internal/lib/provider/oebb"
+	"trk/internal/lib/provider/plugin"
+	"trk/internal/lib/provider/replay"
+	"trk/internal/lib/provider/sncf"
+	"trk/internal/lib/recorder"
+	"trk/internal/lib/transfer"
 	"trk/internal/lib/types"
-)
 
-const TextDestinationSelect = "Select Destination"
+	"github.com/godbus/dbus/v5"
+)
 
-var StationActions = []notify.Action{
-	{Key: "show-station", Label: "Show on Map"},
+// baseURLSetter is a provider of an onboard portal which can be pointed at another url, e.g. a fake portal
+type baseURLSetter interface {
+	SetBaseURL(baseURL string)
 }
 
-var TrainActions = []notify.Action{
-	{Key: "train", Label: "Show on Vagonweb.cz"},
+// frontend shows the state of the ride, either in the tray or in the terminal
+type frontend interface {
+	Notify(summary, body string, t helper.NotificationType, data any)
+	setIdle()
+	setActive()
+	// update shows the status, changes are the events caused by it
+	update(status types.Status, changes []events.Event)
+	// updateConnections shows the onward connections at stop, a nil stop hides them
+	updateConnections(stop *types.Stop, connections []types.Connection)
 }
 
-// UI Variables
-var (
-	labelTrain       *gtk.MenuItem
-	labelSpeed       *gtk.MenuItem
-	labelNextStop    *gtk.MenuItem
-	labelDestination *gtk.MenuItem
-	labelIdle        *gtk.MenuItem
-	indicator        *appindicator.Indicator
-	notifyStore      *helper.NotifyStore
-)
+var ui frontend
 
 // State
 var (
-	currentTrain    *types.Train
-	currentTrip     types.Trip
-	destinationStop *types.Stop
+	currentTrain *types.Train
+	currentTrip  types.Trip
+	gpxRecorder  *recorder.GPXRecorder
+	arrivalAlarm *alarm.Alarm
+	// systemBus is nil if the system D-Bus is not available
+	systemBus *dbus.Conn
+	// connectivityMeter is set if the train's WiFi should be metered while the internet is weak
+	connectivityMeter *networkmanager.Meter
 )
 
 func main() {
-	notifyStore = &helper.NotifyStore{}
-
-	initNotifier()
-	defer cleanupNotifier()
-	gtk.Init(nil)
-	defer files.CleanUp()
-	defer gtk.Main()
-
-	var err error
-
-	item, err := gtk.MenuItemNewWithLabel("Quit")
+	gpxDir := flag.String("gpx", "", "record the trip as GPX tracks into this directory")
+	replayDir := flag.String("replay", "", "play back a recorded iceportal.de session from this directory instead of probing")
+	replaySpeed := flag.Float64("replay-speed", 1, "playback speed of --replay, 0 plays without pauses")
+	replaySeek := flag.String("replay-seek", "", "start --replay at this offset into the recording, e.g. 15m, or at this RFC 3339 time")
+	recordDir := flag.String("record", "", "store every raw portal response in this directory")
+	iceURL := flag.String("ice-url", "", "use this local url instead of https://iceportal.de, e.g. for cmd/fakeportal")
+	oebbURL := flag.String("oebb-url", "", "use this local url instead of https://railnet.oebb.at, e.g. for cmd/fakeportal --railnet")
+	cdURL := flag.String("cd-url", "", "use this local url instead of http://cdwifi.cz")
+	sncfURL := flag.String("sncf-url", "", "use this local url instead of https://wifi.sncf")
+	gpsdAddress := flag.String("gpsd", "", "follow the position of a GPS receiver through gpsd at this address, e.g. "+gpsd.DefaultAddress+", on trains without a portal")
+	gtfsFeed := flag.String("gtfs", "", "identify the train from the position with this GTFS feed (zip or directory) on trains without a portal")
+	var plugins stringsFlag
+	flag.Var(&plugins, "plugin", "run this executable as a provider, can be repeated, see internal/lib/provider/plugin")
+	headless := flag.Bool("headless", false, "run without tray icon and desktop notifications, print the status to stdout")
+	format := flag.String("format", "text", "output format of --headless, text or json")
+	alarms := flag.String("alarms", "15m,5m,0s", "remind this long before the arrival at the destination")
+	minTransfer := flag.Duration("min-transfer", 4*time.Minute, "time needed to change trains, connections departing earlier are unreachable")
+	transferAlerts := flag.String("transfer-alerts", "5m,3m", "notify when the time to change to the pinned connection falls below these")
+	nmMetered := flag.Bool("nm-metered", false, "mark the train's WiFi as metered in NetworkManager while the internet is weak")
+	bindInterface := flag.String("interface", "", "reach the portals through this network interface, by default the one of the train's WiFi")
+	bindDNS := flag.String("dns", "", "comma separated DNS servers for the portals, by default the ones of the train's WiFi")
+	flag.Parse()
+
+	thresholds, err := alarm.ParseThresholds(*alarms)
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	menu, err := gtk.MenuNew()
+	arrivalAlarm = alarm.NewAlarm(thresholds)
+	transferMonitor = transfer.NewMonitor(*minTransfer)
+	transferThresholds, err := alarm.ParseThresholds(*transferAlerts)
 	if err != nil {
 		log.Fatal(err)
 	}
-	for _, mi := range createLabels() {
-		menu.Add(mi)
+	transferPin = transfer.NewPin(transferThresholds)
+
+	for _, p := range providers {
+		var baseURL string
+		switch p.(type) {
+		case *ice.Provider:
+			baseURL = *iceURL
+		case *oebb.Provider:
+			baseURL = *oebbURL
+		case *cd.Provider:
+			baseURL = *cdURL
+		case *sncf.Provider:
+			baseURL = *sncfURL
+		}
+		if portalProvider, ok := p.(baseURLSetter); ok && baseURL != "" {
+			portalProvider.SetBaseURL(baseURL)
+		}
 	}
 
-	_ = item.Connect("activate", func() {
-		gtk.MainQuit()
-	})
-
-	menu.Add(item)
-	item.Show()
-	menu.ShowAll()
+	if *gpsdAddress != "" {
+		// gpsd goes right before GeoClue, which stays the last resort
+		providers = insertBeforeFallbacks(providers, gpsd.NewGPSDProvider(*gpsdAddress))
+	}
 
-	indicator = appindicator.New("train-tracker", "", appindicator.CategoryOther)
+	for _, command := range plugins {
+		providers = insertBeforeFallbacks(providers, plugin.NewPluginProvider(command))
+	}
 
-	indicator.SetMenu(menu)
-	indicator.SetLabel("Trk", "guide")
-	indicator.SetStatus(appindicator.StatusActive)
-	indicator.SetIconFull(files.GetIconPath("images/trk.png"), "Icon")
-	go eventHandler()
-	go runProviders()
-}
+	bindingOverride.Interface = *bindInterface
+	for _, server := range strings.Split(*bindDNS, ",") {
+		if server = strings.TrimSpace(server); server != "" {
+			bindingOverride.DNS = append(bindingOverride.DNS, server)
+		}
+	}
 
-func createLabels() []*gtk.MenuItem {
-	labelIdle = mustCreateMenuItemWithLabel("Waiting for Train", nil)
-	labelNextStop = mustCreateMenuItemWithLabel("next", func() {
-		if currentTrip != nil {
-			if nextStop := currentTrip.GetNextStop(); nextStop != nil {
-				external.OpenOEPNVKarte(nextStop.Location)
-			}
+	if *recordDir != "" {
+		responseRecorder, err := recorder.NewResponseRecorder(*recordDir)
+		if err != nil {
+			log.Fatal(err)
 		}
-	})
-	labelDestination = mustCreateMenuItemWithLabel(TextDestinationSelect, nil)
-	labelTrain = mustCreateMenuItemWithLabel("train", func() {
-		if currentTrain != nil {
-			external.OpenVagonWeb(currentTrain)
+		for _, p := range providers {
+			if iceProvider, ok := p.(*ice.Provider); ok {
+				iceProvider.Recorder = responseRecorder
+			}
 		}
-	})
-	labelSpeed = mustCreateMenuItemWithLabel("speed", nil)
-	return []*gtk.MenuItem{
-		labelIdle,
-		labelTrain,
-		labelNextStop,
-		labelDestination,
-		labelSpeed,
 	}
-}
 
-func mustCreateMenuItemWithLabel(text string, onActivate func()) *gtk.MenuItem {
-	mi, err := gtk.MenuItemNew()
-	if err != nil {
-		panic(err)
-	}
-	mi.SetLabel(text)
-	if onActivate != nil {
-		mi.Connect("activate", onActivate)
+	if *replayDir != "" {
+		replayProvider := replay.NewReplayProvider(*replayDir)
+		replayProvider.SetSpeed(*replaySpeed)
+		if *replaySeek != "" {
+			if d, err := time.ParseDuration(*replaySeek); err == nil {
+				replayProvider.SeekOffset(d)
+			} else if t, err := time.Parse(time.RFC3339, *replaySeek); err == nil {
+				replayProvider.Seek(t)
+			} else {
+				log.Fatalf("Invalid --replay-seek %q, expected a duration or an RFC 3339 time\n", *replaySeek)
+			}
+		}
+		providers = []provider.Provider{replayProvider}
 	}
-	return mi
-}
 
-func buildTransferMenu(stops []types.Stop) {
-	menu, err := gtk.MenuNew()
+	systemBus, err = dbus.ConnectSystemBus()
 	if err != nil {
-		panic(err)
+		log.Printf("System bus not available, probing every minute: %s\n", err.Error())
+		systemBus = nil
+	} else {
+		defer systemBus.Close()
+		for _, p := range providers {
+			if geoclueProvider, ok := p.(*geoclue.Provider); ok {
+				geoclueProvider.GeoClue = geoclue.NewDBus(systemBus)
+			}
+		}
 	}
-	for _, stop := range stops {
-		mi, err := gtk.MenuItemNew()
+
+	if *gtfsFeed != "" {
+		feed, err := gtfs.Load(*gtfsFeed)
 		if err != nil {
-			panic(err)
+			log.Fatal(err)
 		}
+		// the trip is matched whenever a provider knows no stops, the fallbacks never do,
+		// plugins and portals only if they send no trip
+		for i, p := range providers {
+			providers[i] = gtfs.Wrap(p, gtfs.NewMatcher(feed))
+		}
+	}
 
-		arrivalStr := ""
-		if !stop.ArrivalTime.IsZero() {
-			arrivalStr = stop.ArrivalTime.Format(" [15:04]")
+	if *nmMetered {
+		if systemBus == nil {
+			log.Println("NetworkManager not available, the WiFi is not marked as metered")
+		} else {
+			connectivityMeter = networkmanager.NewMeter(networkmanager.NewDBus(systemBus))
+			defer restoreMetered()
 		}
-		labelText := fmt.Sprintf("%s (%s)%s", stop.Name, stop.Track, arrivalStr)
-		mi.SetLabel(labelText)
-		stopId := stop.Id // just a local copy
-		_ = mi.Connect("activate", func(obj *gtk.MenuItem) {
-			if stop := currentTrip.GetStop(stopId); stop != nil {
-				destinationStop = stop
-			}
+	}
+
+	if *gpxDir != "" {
+		gpxRecorder = recorder.NewGPXRecorder(*gpxDir)
+		defer gpxRecorder.Close()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-		})
-		menu.Add(mi)
+	if *headless {
+		h, err := newHeadless(os.Stdout, *format)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ui = h
+		runHeadless(ctx)
+		return
 	}
-	labelDestination.SetSubmenu(menu)
-	menu.ShowAll()
+	runGUI(ctx, cancel)
+}
+
+// run starts the event handler and the providers and returns a channel
+// which is closed once the providers stopped after ctx has been cancelled
+func run(ctx context.Context) <-chan struct{} {
+	go eventHandler()
+	providersDone := make(chan struct{})
+	go func() {
+		runProviders(ctx, networkChanges(ctx))
+		close(providersDone)
+	}()
+	return providersDone
 }
 
-func guiSetActive() {
-	glib.IdleAdd(func() {
-		labelIdle.Hide()
-		labelTrain.Show()
-		labelDestination.Show()
-		labelSpeed.Show()
-		labelNextStop.Show()
-	})
+// stringsFlag collects the values of a flag given several times
+type stringsFlag []string
 
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
 }
 
-func guiSetIdle() {
-	glib.IdleAdd(func() {
-		labelIdle.Show()
-		labelTrain.Hide()
-		labelDestination.Hide()
-		labelSpeed.Hide()
-		labelNextStop.Hide()
-	})
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
 }
@@ -1,3 +1,5 @@
+//go:build !headless
+
 package main
 
 import (
@@ -5,6 +7,7 @@ import (
 	"github.com/esiqveland/notify"
 	"github.com/godbus/dbus/v5"
 	"log"
+	"trk/internal/lib/alarm"
 	"trk/internal/lib/external"
 	"trk/internal/lib/files"
 	"trk/internal/lib/helper"
@@ -33,10 +36,17 @@ func initNotifier() {
 
 func sendNotification(summary, body string, t helper.NotificationType, data any) {
 	var actions []notify.Action
+	var hints map[string]dbus.Variant
 	switch t {
+	case helper.AlarmNotification:
+		actions = AlarmActions
+		hints = map[string]dbus.Variant{
+			"urgency":    dbus.MakeVariant(byte(2)), // critical
+			"sound-name": dbus.MakeVariant("alarm-clock-elapsed"),
+		}
 	case helper.TrainNotification:
 		actions = TrainActions
-	case helper.StationNotification:
+	case helper.StationNotification, helper.PlatformNotification, helper.DestinationPlatformNotification:
 		actions = StationActions
 	}
 	id, err := notifier.SendNotification(notify.Notification{
@@ -46,7 +56,7 @@ func sendNotification(summary, body string, t helper.NotificationType, data any)
 		Summary:       summary,
 		Body:          body,
 		Actions:       actions,
-		Hints:         nil,
+		Hints:         hints,
 		ExpireTimeout: 20,
 	})
 	if err != nil {
@@ -66,6 +76,11 @@ func onNotifyAction(action *notify.ActionInvokedSignal) {
 		if train, ok := data.(*types.Train); ok {
 			external.OpenVagonWeb(train)
 		}
+	case "snooze":
+		data := notifyStore.GetData(action.ID)
+		if reminder, ok := data.(alarm.Reminder); ok {
+			arrivalAlarm.Snooze(reminder)
+		}
 	case "show-station":
 		data := notifyStore.GetData(action.ID)
 		if location, ok := data.(types.Location); ok {
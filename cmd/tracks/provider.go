@@ -1,53 +1,254 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 	"trk/internal/lib/helper"
+	"trk/internal/lib/netbind"
+	"trk/internal/lib/netwatch"
+	"trk/internal/lib/networkmanager"
 	"trk/internal/lib/provider"
+	"trk/internal/lib/provider/cd"
+	"trk/internal/lib/provider/geoclue"
 	"trk/internal/lib/provider/ice"
+	"trk/internal/lib/provider/oebb"
+	"trk/internal/lib/provider/sncf"
 )
 
+// probeInterval is the fallback for probing when no network change is reported
+const probeInterval = 60 * time.Second
+
+// settleRetries is how often probing is repeated after a network change, because
+// DNS and the portal are often not ready right after connecting
+const settleRetries = 3
+
 var providers []provider.Provider
 var currentProvider provider.Provider
 
 func init() {
 	providers = []provider.Provider{
 		ice.NewICEProvider(),
+		oebb.NewOEBBProvider(),
+		cd.NewCDProvider(),
+		sncf.NewSNCFProvider(),
+		// the last resort, it only knows the position
+		geoclue.NewGeoClueProvider(),
 	}
 	currentProvider = nil
 }
 
-func runProviders() {
+// networkChanges reports network changes and resumes from sleep, it returns nil without a system bus
+func networkChanges(ctx context.Context) <-chan string {
+	if systemBus == nil {
+		return nil
+	}
+	reasons, err := netwatch.Watch(ctx, systemBus)
+	if err != nil {
+		log.Printf("Not watching the network, probing every minute: %s\n", err.Error())
+		return nil
+	}
+	return reasons
+}
+
+// runProviders probes all providers until one of them is available and runs it.
+// Probing happens every probeInterval and immediately when networkChanges reports something.
+// When the provider stops, probing starts again. It returns once ctx is cancelled.
+func runProviders(ctx context.Context, networkChanges <-chan string) {
 	timeout := time.Second * 0
-	var ok bool
-	var err error
+	retries := 0
 	for {
-		guiSetIdle()
+		ui.setIdle()
 		select {
+		case <-ctx.Done():
+			return
+		case reason := <-networkChanges:
+			log.Printf("Probing, %s\n", reason)
+			retries = settleRetries
 		case <-time.After(timeout):
-			timeout = time.Second * 60
-			for _, p := range providers {
-				ok, err = p.Probe()
-				if err != nil {
-					fmt.Println("Error", err.Error())
-				}
-				if !ok {
-					continue
-				}
-				//we can try using the provider now
-				guiSetActive()
-				sendNotification(
-					"Connected",
-					"Connected to ICE Status API",
-					helper.InitNotification,
-					nil,
-				)
-				currentProvider = p
-				currentProvider.Run(statusChan)
-				currentProvider = nil
+		}
 
+		p := probe()
+		if p == nil {
+			timeout = probeInterval
+			if retries > 0 {
+				retries--
+				timeout = 5 * time.Second
 			}
+			continue
+		}
+		retries = 0
+		timeout = probeInterval
+
+		//we can try using the provider now
+		ui.setActive()
+		body := "Connected to the onboard portal"
+		if _, ok := p.(provider.Fallback); ok {
+			body = "No onboard portal, following the GPS position"
+		}
+		ui.Notify(
+			"Connected",
+			body,
+			helper.InitNotification,
+			nil,
+		)
+		currentProvider = p
+		err := runProvider(ctx, p, networkChanges)
+		currentProvider = nil
+		// the next provider may be another train, its stops would never pass the destination
+		setDestination(nil)
+		restoreMetered()
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errPortalFound) {
+			// probe again right away to switch to the portal
+			timeout = 0
+			continue
+		}
+		if err != nil {
+			fmt.Println("Provider stopped:", err.Error())
+		}
+		ui.Notify(
+			"Disconnected",
+			"Lost connection to the train",
+			helper.InitNotification,
+			nil,
+		)
+	}
+}
+
+// errPortalFound ends a fallback provider once a portal is available
+var errPortalFound = errors.New("found the portal of the train")
+
+// runProvider runs p until it stops. A fallback provider is stopped as soon as
+// a portal can be probed, which is tried every probeInterval and on network changes.
+func runProvider(ctx context.Context, p provider.Provider, networkChanges <-chan string) error {
+	if _, ok := p.(provider.Fallback); !ok {
+		return p.Run(ctx, statusChan)
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	found := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-runCtx.Done():
+				return
+			case reason := <-networkChanges:
+				log.Printf("Probing the portals, %s\n", reason)
+			case <-time.After(probeInterval):
+			}
+			if probePortal() != nil {
+				close(found)
+				cancel()
+				return
+			}
+		}
+	}()
+	err := p.Run(runCtx, statusChan)
+	select {
+	case <-found:
+		return errPortalFound
+	default:
+		return err
+	}
+}
+
+// bindingOverride is set from the command line and takes precedence over NetworkManager
+var bindingOverride netbind.Binding
+
+// currentWifi returns the active WiFi connections, ok is false if NetworkManager can't tell
+func currentWifi() (connections []networkmanager.WifiConnection, ok bool) {
+	if systemBus == nil {
+		return nil, false
+	}
+	connections, err := networkmanager.NewDBus(systemBus).ActiveWifiConnections()
+	if err != nil {
+		log.Printf("Error reading the WiFi networks, probing all providers: %s\n", err.Error())
+		return nil, false
+	}
+	return connections, true
+}
+
+// bindingFor returns the interface and the DNS servers of the provider's WiFi, so its portal
+// is reached even while a VPN is connected
+func bindingFor(p provider.Provider, connections []networkmanager.WifiConnection) netbind.Binding {
+	binding := bindingOverride
+	for _, connection := range connections {
+		if !contains(p.GetSSIDs(), connection.SSID) {
+			continue
+		}
+		if binding.Interface == "" {
+			binding.Interface = connection.Interface
+		}
+		if len(binding.DNS) == 0 {
+			binding.DNS = connection.Nameservers
+		}
+		break
+	}
+	return binding
+}
+
+// probe returns the first available provider of the current WiFi network, nil if there is none
+func probe() provider.Provider {
+	return probeProviders(providers)
+}
+
+// probePortal returns the first available provider which is not a fallback, nil if there is none
+func probePortal() provider.Provider {
+	var portals []provider.Provider
+	for _, p := range providers {
+		if _, ok := p.(provider.Fallback); !ok {
+			portals = append(portals, p)
+		}
+	}
+	return probeProviders(portals)
+}
+
+func probeProviders(providers []provider.Provider) provider.Provider {
+	connections, known := currentWifi()
+	var ssids []string
+	if known {
+		ssids = []string{}
+		for _, connection := range connections {
+			ssids = append(ssids, connection.SSID)
+		}
+	}
+	for _, p := range provider.ForSSIDs(providers, ssids) {
+		if bindable, ok := p.(provider.Bindable); ok {
+			bindable.Bind(bindingFor(p, connections))
+		}
+		ok, err := p.Probe()
+		if err != nil {
+			fmt.Println("Error", err.Error())
+		}
+		if ok {
+			return p
+		}
+	}
+	return nil
+}
+
+// insertBeforeFallbacks adds p after the portals, so it is probed before the providers which only know the position
+func insertBeforeFallbacks(providers []provider.Provider, p provider.Provider) []provider.Provider {
+	at := len(providers)
+	for i, existing := range providers {
+		if _, ok := existing.(provider.Fallback); ok {
+			at = i
+			break
+		}
+	}
+	return append(providers[:at:at], append([]provider.Provider{p}, providers[at:]...)...)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
 		}
 	}
+	return false
 }
@@ -0,0 +1,130 @@
+// Package alarm reminds about the arrival at the destination.
+package alarm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"trk/internal/lib/types"
+)
+
+// Reminder is due when the remaining time to the destination falls below Threshold
+type Reminder struct {
+	Stop      types.Stop
+	Threshold time.Duration
+	Remaining time.Duration
+	// Snoozed is set if this reminder repeats a snoozed one
+	Snoozed bool
+}
+
+// Alarm keeps track of the reminders for the destination. The times passed to Check are
+// the status times, so recorded sessions trigger the alarm just like live ones.
+type Alarm struct {
+	Thresholds   []time.Duration
+	SnoozePeriod time.Duration
+
+	mu           sync.Mutex
+	stopId       string
+	fired        map[time.Duration]bool
+	lastCheck    time.Time
+	snoozedUntil time.Time
+	snoozed      *Reminder
+}
+
+// NewAlarm creates an alarm reminding when the remaining time falls below each of the thresholds
+func NewAlarm(thresholds []time.Duration) *Alarm {
+	sorted := append([]time.Duration(nil), thresholds...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] > sorted[j]
+	})
+	return &Alarm{
+		Thresholds:   sorted,
+		SnoozePeriod: 2 * time.Minute,
+		fired:        make(map[time.Duration]bool),
+	}
+}
+
+// ParseThresholds parses a comma separated list of durations like "15m,5m,0s"
+func ParseThresholds(s string) ([]time.Duration, error) {
+	var thresholds []time.Duration
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		d, err := time.ParseDuration(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid alarm %q: %w", part, err)
+		}
+		thresholds = append(thresholds, d)
+	}
+	return thresholds, nil
+}
+
+// SetDestination resets the alarm for a new destination, an empty id disables it
+func (a *Alarm) SetDestination(stopId string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stopId = stopId
+	a.fired = make(map[time.Duration]bool)
+	a.snoozedUntil = time.Time{}
+	a.snoozed = nil
+}
+
+// Destination returns the id of the current destination, empty if there is none
+func (a *Alarm) Destination() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stopId
+}
+
+// Snooze suppresses reminders for SnoozePeriod and repeats the last one afterwards
+func (a *Alarm) Snooze(reminder Reminder) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if reminder.Stop.Id != a.stopId {
+		return
+	}
+	a.snoozedUntil = a.lastCheck.Add(a.SnoozePeriod)
+	a.snoozed = &reminder
+}
+
+// Check returns the reminder which is due at now for the destination stop.
+// If several thresholds have been crossed since the last check, only the most urgent one is returned.
+func (a *Alarm) Check(now time.Time, stop types.Stop) (Reminder, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lastCheck = now
+	if a.stopId == "" || stop.Id != a.stopId || stop.Passed || stop.ArrivalTime.IsZero() {
+		return Reminder{}, false
+	}
+	if now.Before(a.snoozedUntil) {
+		return Reminder{}, false
+	}
+	remaining := stop.ArrivalTime.Sub(now)
+
+	due := -1
+	for i, threshold := range a.Thresholds {
+		if remaining <= threshold && !a.fired[threshold] {
+			due = i
+		}
+	}
+	if due == -1 {
+		if a.snoozed != nil {
+			reminder := *a.snoozed
+			reminder.Stop = stop
+			reminder.Remaining = remaining
+			reminder.Snoozed = true
+			a.snoozed = nil
+			return reminder, true
+		}
+		return Reminder{}, false
+	}
+	for _, threshold := range a.Thresholds[:due+1] {
+		a.fired[threshold] = true
+	}
+	a.snoozed = nil
+	return Reminder{Stop: stop, Threshold: a.Thresholds[due], Remaining: remaining}, true
+}
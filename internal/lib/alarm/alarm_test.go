@@ -0,0 +1,65 @@
+package alarm
+
+import (
+	"testing"
+	"time"
+	"trk/internal/lib/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlarm(t *testing.T) {
+	thresholds, err := ParseThresholds("5m, 15m,0s")
+	assert.NoError(t, err)
+	a := NewAlarm(thresholds)
+	assert.Equal(t, []time.Duration{15 * time.Minute, 5 * time.Minute, 0}, a.Thresholds)
+
+	now := time.Date(2023, 2, 25, 14, 0, 0, 0, time.UTC)
+	koeln := types.Stop{Id: "8000207", Name: "Köln Hbf", ArrivalTime: now.Add(20 * time.Minute)}
+
+	_, ok := a.Check(now, koeln)
+	assert.False(t, ok, "there is no destination yet")
+	a.SetDestination(koeln.Id)
+	_, ok = a.Check(now, koeln)
+	assert.False(t, ok)
+
+	reminder, ok := a.Check(now.Add(6*time.Minute), koeln)
+	assert.True(t, ok)
+	assert.Equal(t, 15*time.Minute, reminder.Threshold)
+	assert.Equal(t, 14*time.Minute, reminder.Remaining)
+	_, ok = a.Check(now.Add(7*time.Minute), koeln)
+	assert.False(t, ok, "a reminder fires only once")
+
+	// the train is delayed, the reminders follow the live arrival time
+	koeln.ArrivalTime = koeln.ArrivalTime.Add(3 * time.Minute)
+	_, ok = a.Check(now.Add(15*time.Minute), koeln)
+	assert.False(t, ok)
+	reminder, ok = a.Check(now.Add(18*time.Minute), koeln)
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Minute, reminder.Threshold)
+
+	a.Snooze(reminder)
+	_, ok = a.Check(now.Add(19*time.Minute), koeln)
+	assert.False(t, ok, "snoozed")
+	reminder, ok = a.Check(now.Add(20*time.Minute), koeln)
+	assert.True(t, ok)
+	assert.True(t, reminder.Snoozed)
+	assert.Equal(t, 3*time.Minute, reminder.Remaining)
+
+	reminder, ok = a.Check(now.Add(24*time.Minute), koeln)
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), reminder.Threshold)
+}
+
+func TestAlarmSkipsMissedThresholds(t *testing.T) {
+	a := NewAlarm([]time.Duration{15 * time.Minute, 5 * time.Minute, 0})
+	now := time.Date(2023, 2, 25, 14, 0, 0, 0, time.UTC)
+	koeln := types.Stop{Id: "8000207", ArrivalTime: now.Add(3 * time.Minute)}
+	a.SetDestination(koeln.Id)
+
+	reminder, ok := a.Check(now, koeln)
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Minute, reminder.Threshold)
+	_, ok = a.Check(now.Add(time.Minute), koeln)
+	assert.False(t, ok)
+}
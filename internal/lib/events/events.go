@@ -0,0 +1,305 @@
+// Package events derives typed events from successive status and trip snapshots.
+package events
+
+import (
+	"log"
+	"sync"
+	"time"
+	"trk/internal/lib/types"
+)
+
+type Type int
+
+const (
+	TrainChanged Type = iota
+	NextStopChanged
+	DelayChanged
+	TrackChanged
+	StopPassed
+	StopCancelled
+	DestinationApproaching
+	GPSLost
+	GPSRestored
+	ConnectivityChanging
+	DelayReasonAdded
+	DestinationChanged
+)
+
+var typeNames = map[Type]string{
+	TrainChanged:           "TrainChanged",
+	NextStopChanged:        "NextStopChanged",
+	DelayChanged:           "DelayChanged",
+	TrackChanged:           "TrackChanged",
+	StopPassed:             "StopPassed",
+	StopCancelled:          "StopCancelled",
+	DestinationApproaching: "DestinationApproaching",
+	GPSLost:                "GPSLost",
+	GPSRestored:            "GPSRestored",
+	ConnectivityChanging:   "ConnectivityChanging",
+	DelayReasonAdded:       "DelayReasonAdded",
+	DestinationChanged:     "DestinationChanged",
+}
+
+func (t Type) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+type Event struct {
+	Type Type
+	// Status is the snapshot which caused the event
+	Status types.Status
+	// Stop is the stop the event is about, for NextStopChanged it is the new next stop,
+	// for DestinationChanged the new destination or nil if it was removed or is unknown
+	Stop *types.Stop
+	// Previous is the state of Stop in the previous snapshot, if there was one
+	Previous *types.Stop
+	// PreviousDelay is the delay of the previous snapshot for DelayChanged and DelayReasonAdded
+	PreviousDelay time.Duration
+	// Destination is set if Stop is the selected destination
+	Destination bool
+	// Reasons are the new delay reasons for DelayReasonAdded
+	Reasons []types.DelayReason
+}
+
+type snapshot struct {
+	status types.Status
+	trip   types.Trip
+}
+
+// Engine compares every snapshot fed to it with the previous one and publishes the differences
+// to all subscribers. Subscribers have to keep reading their channel, events which do not fit
+// into a subscriber's buffer are dropped for that subscriber.
+type Engine struct {
+	// ApproachWarning is how long before the arrival at the destination DestinationApproaching is sent
+	ApproachWarning time.Duration
+	// ConnectivityWarning is how long before a forecast connectivity change ConnectivityChanging is sent
+	ConnectivityWarning time.Duration
+
+	mu            sync.Mutex
+	subscribers   map[int]chan Event
+	nextId        int
+	previous      *snapshot
+	destinationId string
+	approached    bool
+	connectivity  types.Connectivity
+}
+
+func NewEngine() *Engine {
+	return &Engine{
+		ApproachWarning:     10 * time.Minute,
+		ConnectivityWarning: 10 * time.Minute,
+		subscribers:         make(map[int]chan Event),
+	}
+}
+
+// Subscribe returns a channel receiving all future events and a function to cancel the subscription
+func (e *Engine) Subscribe() (<-chan Event, func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	id := e.nextId
+	e.nextId++
+	ch := make(chan Event, 32)
+	e.subscribers[id] = ch
+	return ch, func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if _, ok := e.subscribers[id]; ok {
+			delete(e.subscribers, id)
+			close(ch)
+		}
+	}
+}
+
+// SetDestination sets the stop for DestinationApproaching, an empty id removes the destination.
+// A new destination is published as DestinationChanged with the stop from the last snapshot.
+func (e *Engine) SetDestination(stopId string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if stopId == e.destinationId {
+		return
+	}
+	e.destinationId = stopId
+	e.approached = false
+	event := Event{Type: DestinationChanged}
+	if e.previous != nil {
+		event.Status = e.previous.status
+		event.Stop = e.previous.trip.GetStop(stopId)
+		event.Destination = event.Stop != nil
+	}
+	e.publish([]Event{event})
+}
+
+// Feed compares the snapshot with the previous one, publishes the resulting events and returns them
+func (e *Engine) Feed(status types.Status, trip types.Trip) []Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	current := &snapshot{status: status, trip: trip}
+	events := diff(e.previous, current)
+	events = append(events, e.destinationEvents(current)...)
+	events = append(events, e.connectivityEvents(current)...)
+	for i := range events {
+		events[i].Destination = e.destinationId != "" && events[i].Stop != nil && events[i].Stop.Id == e.destinationId
+	}
+	e.previous = current
+	e.publish(events)
+	return events
+}
+
+// publish sends the events to all subscribers, e.mu has to be held. Holding the lock keeps
+// unsubscribe from closing a channel in between, and publish never blocks so a stalled
+// subscriber cannot stall the engine.
+func (e *Engine) publish(events []Event) {
+	for _, event := range events {
+		for id, ch := range e.subscribers {
+			select {
+			case ch <- event:
+			default:
+				log.Printf("Dropping event %s for subscriber %d, it is not reading\n", event.Type, id)
+			}
+		}
+	}
+}
+
+// diff returns the events between two snapshots, previous is nil for the first snapshot
+func diff(previous, current *snapshot) []Event {
+	status := current.status
+	var events []Event
+	if previous == nil || previous.status.Train.Id != status.Train.Id {
+		events = append(events, Event{Type: TrainChanged, Status: status})
+		if status.NextStop.Id != "" {
+			events = append(events, Event{Type: NextStopChanged, Status: status, Stop: stopPtr(status.NextStop)})
+		}
+		if status.Delay != 0 {
+			events = append(events, Event{Type: DelayChanged, Status: status})
+		}
+		if status.GPSLost {
+			events = append(events, Event{Type: GPSLost, Status: status})
+		}
+		if reasons := delayReasons(current.trip); len(reasons) > 0 {
+			events = append(events, Event{Type: DelayReasonAdded, Status: status, Reasons: reasons})
+		}
+		for _, stop := range current.trip {
+			if stop.Passed {
+				continue
+			}
+			if stop.TrackChanged() {
+				events = append(events, Event{Type: TrackChanged, Status: status, Stop: stopPtr(stop)})
+			}
+			if stop.Cancelled {
+				events = append(events, Event{Type: StopCancelled, Status: status, Stop: stopPtr(stop)})
+			}
+		}
+		return events
+	}
+
+	prev := previous.status
+	if status.NextStop.Id != prev.NextStop.Id {
+		events = append(events, Event{
+			Type:     NextStopChanged,
+			Status:   status,
+			Stop:     stopPtr(status.NextStop),
+			Previous: stopPtr(prev.NextStop),
+		})
+	}
+	if status.Delay != prev.Delay {
+		events = append(events, Event{Type: DelayChanged, Status: status, PreviousDelay: prev.Delay})
+	}
+	if status.GPSLost && !prev.GPSLost {
+		events = append(events, Event{Type: GPSLost, Status: status})
+	} else if !status.GPSLost && prev.GPSLost {
+		events = append(events, Event{Type: GPSRestored, Status: status})
+	}
+
+	if reasons := newDelayReasons(delayReasons(previous.trip), delayReasons(current.trip)); len(reasons) > 0 {
+		events = append(events, Event{Type: DelayReasonAdded, Status: status, Reasons: reasons, PreviousDelay: prev.Delay})
+	}
+
+	for _, stop := range current.trip {
+		before := previous.trip.GetStop(stop.Id)
+		if before == nil {
+			continue
+		}
+		if stop.Track != before.Track {
+			events = append(events, Event{Type: TrackChanged, Status: status, Stop: stopPtr(stop), Previous: before})
+		}
+		if stop.Passed && !before.Passed {
+			events = append(events, Event{Type: StopPassed, Status: status, Stop: stopPtr(stop), Previous: before})
+		}
+		if stop.Cancelled && !before.Cancelled {
+			events = append(events, Event{Type: StopCancelled, Status: status, Stop: stopPtr(stop), Previous: before})
+		}
+	}
+	return events
+}
+
+func (e *Engine) destinationEvents(current *snapshot) []Event {
+	if e.destinationId == "" || e.approached {
+		return nil
+	}
+	stop := current.trip.GetStop(e.destinationId)
+	if stop == nil || stop.Passed || stop.ArrivalTime.IsZero() {
+		return nil
+	}
+	if stop.ArrivalTime.Sub(current.status.Timestamp) > e.ApproachWarning {
+		return nil
+	}
+	e.approached = true
+	return []Event{{Type: DestinationApproaching, Status: current.status, Stop: stop}}
+}
+
+// connectivityEvents announces every forecast change of the connectivity once, as soon as
+// it is less than ConnectivityWarning ahead
+func (e *Engine) connectivityEvents(current *snapshot) []Event {
+	c := current.status.Connectivity
+	if c.NextState == "" || c.NextState == c.CurrentState {
+		e.connectivity = types.Connectivity{}
+		return nil
+	}
+	if c.CurrentState == e.connectivity.CurrentState && c.NextState == e.connectivity.NextState {
+		return nil
+	}
+	if c.NextIn > e.ConnectivityWarning {
+		return nil
+	}
+	e.connectivity = c
+	return []Event{{Type: ConnectivityChanging, Status: current.status}}
+}
+
+// delayReasons returns the distinct delay reasons of all stops ahead
+func delayReasons(trip types.Trip) []types.DelayReason {
+	var reasons []types.DelayReason
+	for _, stop := range trip {
+		if stop.Passed {
+			continue
+		}
+		reasons = append(reasons, newDelayReasons(reasons, stop.DelayReasons)...)
+	}
+	return reasons
+}
+
+// newDelayReasons returns the reasons of current which are not part of previous
+func newDelayReasons(previous, current []types.DelayReason) []types.DelayReason {
+	var added []types.DelayReason
+Outer:
+	for _, reason := range current {
+		for _, known := range previous {
+			if reason == known {
+				continue Outer
+			}
+		}
+		for _, known := range added {
+			if reason == known {
+				continue Outer
+			}
+		}
+		added = append(added, reason)
+	}
+	return added
+}
+
+func stopPtr(stop types.Stop) *types.Stop {
+	return &stop
+}
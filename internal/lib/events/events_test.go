@@ -0,0 +1,122 @@
+package events
+
+import (
+	"fmt"
+	"testing"
+	"time"
+	"trk/internal/lib/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func eventTypes(events []Event) []Type {
+	result := make([]Type, 0, len(events))
+	for _, event := range events {
+		result = append(result, event.Type)
+	}
+	return result
+}
+
+func TestEngine(t *testing.T) {
+	now := time.Date(2023, 2, 25, 11, 40, 0, 0, time.UTC)
+	train := types.Train{Id: "ICE9048", DisplayName: "ICE 505"}
+	erlangen := types.Stop{Id: "8001844", Name: "Erlangen", Track: "4", ArrivalTime: now.Add(10 * time.Minute)}
+	nuernberg := types.Stop{Id: "8000284", Name: "Nürnberg Hbf", Track: "8", ArrivalTime: now.Add(30 * time.Minute)}
+	status := types.Status{Train: train, Timestamp: now, NextStop: erlangen}
+
+	e := NewEngine()
+	events, unsubscribe := e.Subscribe()
+	defer unsubscribe()
+	e.SetDestination(nuernberg.Id)
+	assert.Equal(t, DestinationChanged, (<-events).Type)
+
+	assert.Equal(t, []Type{TrainChanged, NextStopChanged}, eventTypes(e.Feed(status, types.Trip{erlangen, nuernberg})))
+	assert.Equal(t, TrainChanged, (<-events).Type)
+	assert.Equal(t, NextStopChanged, (<-events).Type)
+	assert.Empty(t, e.Feed(status, types.Trip{erlangen, nuernberg}))
+
+	// the platform changes and the GPS drops out
+	status.GPSLost = true
+	changed := nuernberg
+	changed.Track = "9"
+	result := e.Feed(status, types.Trip{erlangen, changed})
+	assert.Equal(t, []Type{GPSLost, TrackChanged}, eventTypes(result))
+	assert.Equal(t, "8", result[1].Previous.Track)
+
+	// erlangen is passed, we are delayed and approach the destination
+	status.GPSLost = false
+	status.Delay = 5 * time.Minute
+	status.Timestamp = now.Add(25 * time.Minute)
+	status.NextStop = changed
+	passed := erlangen
+	passed.Passed = true
+	result = e.Feed(status, types.Trip{passed, changed})
+	assert.Equal(t, []Type{NextStopChanged, DelayChanged, GPSRestored, StopPassed, DestinationApproaching}, eventTypes(result))
+	assert.Equal(t, "Erlangen", result[0].Previous.Name)
+	assert.Equal(t, "Nürnberg Hbf", result[4].Stop.Name)
+	// the destination is only announced once
+	assert.Empty(t, e.Feed(status, types.Trip{passed, changed}))
+
+	status.Connectivity = types.Connectivity{CurrentState: "HIGH", NextState: "WEAK", NextIn: 30 * time.Minute}
+	assert.Empty(t, e.Feed(status, types.Trip{passed, changed}))
+	status.Connectivity.NextIn = 2 * time.Minute
+	assert.Equal(t, []Type{ConnectivityChanging}, eventTypes(e.Feed(status, types.Trip{passed, changed})))
+	status.Connectivity.NextIn = time.Minute
+	assert.Empty(t, e.Feed(status, types.Trip{passed, changed}))
+
+	status.Train = types.Train{Id: "RE5"}
+	assert.Equal(t, []Type{TrainChanged, NextStopChanged, DelayChanged}, eventTypes(e.Feed(status, types.Trip{})))
+}
+
+func TestFeedWhileUnsubscribing(t *testing.T) {
+	e := NewEngine()
+	status := types.Status{Train: types.Train{Id: "ICE9048"}}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			status.Train.Id = fmt.Sprintf("ICE%d", i)
+			e.Feed(status, nil)
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		_, unsubscribe := e.Subscribe()
+		unsubscribe()
+	}
+	<-done
+}
+
+func TestFeedDoesNotBlockOnFullSubscriber(t *testing.T) {
+	e := NewEngine()
+	events, unsubscribe := e.Subscribe()
+	defer unsubscribe()
+	status := types.Status{}
+	for i := 0; i < 100; i++ {
+		status.Train.Id = fmt.Sprintf("ICE%d", i)
+		assert.Equal(t, []Type{TrainChanged}, eventTypes(e.Feed(status, nil)))
+	}
+	assert.Len(t, events, cap(events))
+	assert.Equal(t, "ICE0", (<-events).Status.Train.Id)
+}
+
+func TestDestinationChanged(t *testing.T) {
+	bonn := types.Stop{Id: "8000044", Name: "Bonn Hbf", Track: "3", ScheduledTrack: "2"}
+	e := NewEngine()
+	events, unsubscribe := e.Subscribe()
+	defer unsubscribe()
+	for range e.Feed(types.Status{Train: types.Train{Id: "ICE9048"}}, types.Trip{bonn}) {
+		<-events
+	}
+
+	e.SetDestination(bonn.Id)
+	event := <-events
+	assert.Equal(t, DestinationChanged, event.Type)
+	assert.True(t, event.Destination)
+	assert.Equal(t, "Bonn Hbf", event.Stop.Name)
+	// setting the same destination again is no change
+	e.SetDestination(bonn.Id)
+	e.SetDestination("")
+	event = <-events
+	assert.Nil(t, event.Stop)
+	assert.False(t, event.Destination)
+}
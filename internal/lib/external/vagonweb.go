@@ -13,7 +13,14 @@ func OpenVagonWeb(train *types.Train) {
 			return
 		}
 		vagonWebLookup := lookup.NewVagonWebLookup()
-		url, err := vagonWebLookup.GetTrainLink(train.LookupString)
+		var url string
+		var err error
+		if train.Operator != "" {
+			// no need to search for trains whose operator is known
+			url = vagonWebLookup.TrainLink(train.Operator, train.Type, train.Line)
+		} else {
+			url, err = vagonWebLookup.GetTrainLink(train.LookupString)
+		}
 		if err == nil {
 
 			err := exec.Command("xdg-open", url).Run()
@@ -0,0 +1,482 @@
+// Package gtfs identifies the train from its position alone, using a GTFS schedule.
+//
+// A feed is loaded from a zip file or a directory with the files of the GTFS reference
+// (https://gtfs.org/schedule/reference/). Only agency.txt, stops.txt, routes.txt, trips.txt,
+// stop_times.txt, calendar.txt, calendar_dates.txt and shapes.txt are read.
+package gtfs
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // the feed names its timezone, which must be known on every system
+	"trk/internal/lib/types"
+)
+
+type Stop struct {
+	Id       string
+	Name     string
+	Location types.Location
+	// Platform is the platform_code of the stop, if the feed models platforms as stops
+	Platform string
+}
+
+type Route struct {
+	Id        string
+	ShortName string
+	LongName  string
+	// Type is the route_type, 2 is rail, 100 to 199 the extended rail types
+	Type int
+}
+
+// IsRail returns true for the rail route types, including the extended ones
+func (r Route) IsRail() bool {
+	return r.Type == 2 || (r.Type >= 100 && r.Type < 200)
+}
+
+type StopTime struct {
+	Stop *Stop
+	// Arrival and Departure are the times since noon minus 12h of the service day,
+	// they exceed 24h for trips running past midnight
+	Arrival   time.Duration
+	Departure time.Duration
+}
+
+type Trip struct {
+	Id        string
+	Route     *Route
+	ServiceId string
+	Headsign  string
+	ShortName string
+	ShapeId   string
+	StopTimes []StopTime
+
+	// sequences are the stop_sequence of the StopTimes, they are sorted by it after loading
+	sequences []int
+}
+
+// service is a calendar entry with its exceptions
+type service struct {
+	weekdays   [7]bool // indexed by time.Weekday
+	start, end string  // YYYYMMDD, empty without calendar.txt entry
+	added      map[string]bool
+	removed    map[string]bool
+}
+
+func (s *service) activeOn(date string, weekday time.Weekday) bool {
+	if s.removed[date] {
+		return false
+	}
+	if s.added[date] {
+		return true
+	}
+	return s.start != "" && s.start <= date && date <= s.end && s.weekdays[weekday]
+}
+
+// Feed is a loaded GTFS feed
+type Feed struct {
+	// Location is the timezone of the first agency, all times of the feed are local to it
+	Location *time.Location
+	Stops    map[string]*Stop
+	Routes   map[string]*Route
+	Trips    map[string]*Trip
+	Shapes   map[string][]types.Location
+
+	shapeSequences map[string][]int
+	services       map[string]*service
+	stopTrips      map[string][]*Trip
+	grid           map[cell][]*Stop
+}
+
+// Load reads the feed from a zip file or a directory
+func Load(path string) (*Feed, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return Parse(os.DirFS(path))
+	}
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return Parse(r)
+}
+
+// Parse reads the feed from the files in fsys
+func Parse(fsys fs.FS) (*Feed, error) {
+	f := &Feed{
+		Location:       time.UTC,
+		Stops:          map[string]*Stop{},
+		Routes:         map[string]*Route{},
+		Trips:          map[string]*Trip{},
+		Shapes:         map[string][]types.Location{},
+		shapeSequences: map[string][]int{},
+		services:       map[string]*service{},
+		stopTrips:      map[string][]*Trip{},
+		grid:           map[cell][]*Stop{},
+	}
+	steps := []struct {
+		name     string
+		optional bool
+		read     func(record) error
+	}{
+		{"agency.txt", true, f.readAgency},
+		{"stops.txt", false, f.readStop},
+		{"routes.txt", false, f.readRoute},
+		{"trips.txt", false, f.readTrip},
+		{"stop_times.txt", false, f.readStopTime},
+		{"calendar.txt", true, f.readCalendar},
+		{"calendar_dates.txt", true, f.readCalendarDate},
+		{"shapes.txt", true, f.readShapePoint},
+	}
+	for _, step := range steps {
+		err := readCSV(fsys, step.name, step.read)
+		if errors.Is(err, fs.ErrNotExist) && step.optional {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	f.index()
+	return f, nil
+}
+
+func (f *Feed) readAgency(r record) error {
+	if f.Location != time.UTC || r.get("agency_timezone") == "" {
+		return nil
+	}
+	location, err := time.LoadLocation(r.get("agency_timezone"))
+	if err != nil {
+		return err
+	}
+	f.Location = location
+	return nil
+}
+
+func (f *Feed) readStop(r record) error {
+	lat, err := r.float("stop_lat")
+	if err != nil {
+		return err
+	}
+	lon, err := r.float("stop_lon")
+	if err != nil {
+		return err
+	}
+	f.Stops[r.get("stop_id")] = &Stop{
+		Id:       r.get("stop_id"),
+		Name:     r.get("stop_name"),
+		Location: types.Location{Latitude: lat, Longitude: lon},
+		Platform: r.get("platform_code"),
+	}
+	return nil
+}
+
+func (f *Feed) readRoute(r record) error {
+	routeType, err := strconv.Atoi(r.get("route_type"))
+	if err != nil {
+		return r.errorf("route_type: %v", err)
+	}
+	f.Routes[r.get("route_id")] = &Route{
+		Id:        r.get("route_id"),
+		ShortName: r.get("route_short_name"),
+		LongName:  r.get("route_long_name"),
+		Type:      routeType,
+	}
+	return nil
+}
+
+func (f *Feed) readTrip(r record) error {
+	route, ok := f.Routes[r.get("route_id")]
+	if !ok {
+		return r.errorf("unknown route %q", r.get("route_id"))
+	}
+	f.Trips[r.get("trip_id")] = &Trip{
+		Id:        r.get("trip_id"),
+		Route:     route,
+		ServiceId: r.get("service_id"),
+		Headsign:  r.get("trip_headsign"),
+		ShortName: r.get("trip_short_name"),
+		ShapeId:   r.get("shape_id"),
+	}
+	return nil
+}
+
+func (f *Feed) readStopTime(r record) error {
+	trip, ok := f.Trips[r.get("trip_id")]
+	if !ok {
+		return r.errorf("unknown trip %q", r.get("trip_id"))
+	}
+	stop, ok := f.Stops[r.get("stop_id")]
+	if !ok {
+		return r.errorf("unknown stop %q", r.get("stop_id"))
+	}
+	sequence, err := strconv.Atoi(r.get("stop_sequence"))
+	if err != nil {
+		return r.errorf("stop_sequence: %v", err)
+	}
+	arrival, err := parseTime(r.get("arrival_time"))
+	if err != nil {
+		return r.errorf("arrival_time: %v", err)
+	}
+	departure, err := parseTime(r.get("departure_time"))
+	if err != nil {
+		return r.errorf("departure_time: %v", err)
+	}
+	if arrival < 0 {
+		arrival = departure
+	}
+	if departure < 0 {
+		departure = arrival
+	}
+	if arrival < 0 {
+		// times are only required at the timepoints, stops in between are skipped
+		return nil
+	}
+	trip.StopTimes = append(trip.StopTimes, StopTime{Stop: stop, Arrival: arrival, Departure: departure})
+	trip.sequences = append(trip.sequences, sequence)
+	return nil
+}
+
+func (f *Feed) service(id string) *service {
+	s, ok := f.services[id]
+	if !ok {
+		s = &service{added: map[string]bool{}, removed: map[string]bool{}}
+		f.services[id] = s
+	}
+	return s
+}
+
+func (f *Feed) readCalendar(r record) error {
+	s := f.service(r.get("service_id"))
+	for weekday, column := range []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"} {
+		s.weekdays[weekday] = r.get(column) == "1"
+	}
+	s.start = r.get("start_date")
+	s.end = r.get("end_date")
+	return nil
+}
+
+func (f *Feed) readCalendarDate(r record) error {
+	s := f.service(r.get("service_id"))
+	switch r.get("exception_type") {
+	case "1":
+		s.added[r.get("date")] = true
+	case "2":
+		s.removed[r.get("date")] = true
+	default:
+		return r.errorf("exception_type %q", r.get("exception_type"))
+	}
+	return nil
+}
+
+func (f *Feed) readShapePoint(r record) error {
+	lat, err := r.float("shape_pt_lat")
+	if err != nil {
+		return err
+	}
+	lon, err := r.float("shape_pt_lon")
+	if err != nil {
+		return err
+	}
+	sequence, err := strconv.Atoi(r.get("shape_pt_sequence"))
+	if err != nil {
+		return r.errorf("shape_pt_sequence: %v", err)
+	}
+	id := r.get("shape_id")
+	f.Shapes[id] = append(f.Shapes[id], types.Location{Latitude: lat, Longitude: lon})
+	f.shapeSequences[id] = append(f.shapeSequences[id], sequence)
+	return nil
+}
+
+// index sorts the stop times and the shapes and indexes the stops by their position and their trips
+func (f *Feed) index() {
+	for _, trip := range f.Trips {
+		sort.Sort(bySequence{sequences: trip.sequences, swap: func(i, j int) {
+			trip.StopTimes[i], trip.StopTimes[j] = trip.StopTimes[j], trip.StopTimes[i]
+		}})
+		trip.sequences = nil
+		for _, stopTime := range trip.StopTimes {
+			stopTrips := f.stopTrips[stopTime.Stop.Id]
+			if len(stopTrips) == 0 || stopTrips[len(stopTrips)-1] != trip {
+				f.stopTrips[stopTime.Stop.Id] = append(stopTrips, trip)
+			}
+		}
+	}
+	for id, points := range f.Shapes {
+		sort.Sort(bySequence{sequences: f.shapeSequences[id], swap: func(i, j int) {
+			points[i], points[j] = points[j], points[i]
+		}})
+	}
+	f.shapeSequences = nil
+	for _, stop := range f.Stops {
+		c := cellOf(stop.Location)
+		f.grid[c] = append(f.grid[c], stop)
+	}
+}
+
+// bySequence sorts by sequences and applies every swap to the sorted data as well
+type bySequence struct {
+	sequences []int
+	swap      func(i, j int)
+}
+
+func (s bySequence) Len() int           { return len(s.sequences) }
+func (s bySequence) Less(i, j int) bool { return s.sequences[i] < s.sequences[j] }
+func (s bySequence) Swap(i, j int) {
+	s.sequences[i], s.sequences[j] = s.sequences[j], s.sequences[i]
+	s.swap(i, j)
+}
+
+// cellSize is the size of the cells of the stop index in degrees
+const cellSize = 0.1
+
+type cell struct {
+	lat, lon int
+}
+
+func cellOf(l types.Location) cell {
+	return cell{lat: int(math.Floor(l.Latitude / cellSize)), lon: int(math.Floor(l.Longitude / cellSize))}
+}
+
+// StopsNear returns the stops within radius meters of l
+func (f *Feed) StopsNear(l types.Location, radius float64) []*Stop {
+	// a degree of latitude is 111 km, a degree of longitude gets shorter towards the poles
+	latCells := int(math.Ceil(radius/111000/cellSize)) + 1
+	lonCells := latCells
+	if c := math.Cos(l.Latitude * math.Pi / 180); c > 0.1 {
+		lonCells = int(math.Ceil(radius/(111000*c)/cellSize)) + 1
+	}
+	center := cellOf(l)
+	var stops []*Stop
+	for lat := center.lat - latCells; lat <= center.lat+latCells; lat++ {
+		for lon := center.lon - lonCells; lon <= center.lon+lonCells; lon++ {
+			for _, stop := range f.grid[cell{lat: lat, lon: lon}] {
+				if distance(l, stop.Location) <= radius {
+					stops = append(stops, stop)
+				}
+			}
+		}
+	}
+	return stops
+}
+
+// TripsAt returns the trips stopping at stop
+func (f *Feed) TripsAt(stop *Stop) []*Trip {
+	return f.stopTrips[stop.Id]
+}
+
+// ActiveOn returns true if the trip runs on the day of date in the timezone of the feed
+func (f *Feed) ActiveOn(trip *Trip, date time.Time) bool {
+	s, ok := f.services[trip.ServiceId]
+	if !ok {
+		return false
+	}
+	date = date.In(f.Location)
+	return s.activeOn(date.Format("20060102"), date.Weekday())
+}
+
+// ServiceDay returns the time the stop times of the day of date are relative to, which is
+// noon minus 12h. It is midnight except for the days of a daylight saving time change.
+func (f *Feed) ServiceDay(date time.Time) time.Time {
+	year, month, day := date.In(f.Location).Date()
+	return time.Date(year, month, day, 12, 0, 0, 0, f.Location).Add(-12 * time.Hour)
+}
+
+// parseTime reads a time of stop_times.txt like 25:10:00, it returns -1 for an empty time
+func parseTime(s string) (time.Duration, error) {
+	if s == "" {
+		return -1, nil
+	}
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	var values [3]int
+	for i, part := range parts {
+		v, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		values[i] = v
+	}
+	return time.Duration(values[0])*time.Hour + time.Duration(values[1])*time.Minute + time.Duration(values[2])*time.Second, nil
+}
+
+// record is a row of a csv file, its values are looked up by the column names of the header
+type record struct {
+	file    string
+	line    int
+	columns map[string]int
+	values  []string
+}
+
+func (r record) get(column string) string {
+	i, ok := r.columns[column]
+	if !ok || i >= len(r.values) {
+		return ""
+	}
+	return strings.TrimSpace(r.values[i])
+}
+
+func (r record) float(column string) (float64, error) {
+	v, err := strconv.ParseFloat(r.get(column), 64)
+	if err != nil {
+		return 0, r.errorf("%s: %v", column, err)
+	}
+	return v, nil
+}
+
+func (r record) errorf(format string, args ...any) error {
+	return fmt.Errorf("%s:%d: %s", r.file, r.line, fmt.Sprintf(format, args...))
+}
+
+// readCSV calls read for every row of the file name in fsys
+func readCSV(fsys fs.FS, name string, read func(record) error) error {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		if i == 0 {
+			column = strings.TrimPrefix(column, "\ufeff")
+		}
+		columns[strings.TrimSpace(column)] = i
+	}
+	for line := 2; ; line++ {
+		values, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if err = read(record{file: name, line: line, columns: columns, values: values}); err != nil {
+			return err
+		}
+	}
+}
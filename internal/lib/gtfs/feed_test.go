@@ -0,0 +1,97 @@
+package gtfs
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loadTestFeed(t *testing.T) *Feed {
+	feed, err := Load("testdata/feed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return feed
+}
+
+func TestLoad(t *testing.T) {
+	feed := loadTestFeed(t)
+	assert.Equal(t, "Europe/Berlin", feed.Location.String())
+	assert.Len(t, feed.Stops, 4)
+	assert.Equal(t, "1", feed.Stops["A"].Platform)
+	assert.True(t, feed.Routes["R1"].IsRail())
+	assert.False(t, feed.Routes["X1"].IsRail())
+
+	// the stop times are sorted by their sequence
+	t1 := feed.Trips["T1"]
+	if assert.Len(t, t1.StopTimes, 4) {
+		assert.Equal(t, "A", t1.StopTimes[0].Stop.Id)
+		assert.Equal(t, "D", t1.StopTimes[3].Stop.Id)
+		assert.Equal(t, 10*time.Hour+7*time.Minute, t1.StopTimes[1].Departure)
+	}
+	assert.Equal(t, 24*time.Hour+3*time.Minute, feed.Trips["T4"].StopTimes[2].Arrival)
+	assert.Len(t, feed.Shapes["S1"], 6)
+	assert.Len(t, feed.TripsAt(feed.Stops["B"]), 5)
+}
+
+func TestLoadZip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feed.zip")
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(out)
+	files, _ := filepath.Glob("testdata/feed/*.txt")
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry, _ := w.Create(filepath.Base(file))
+		_, _ = entry.Write(content)
+	}
+	assert.NoError(t, w.Close())
+	assert.NoError(t, out.Close())
+
+	feed, err := Load(path)
+	assert.NoError(t, err)
+	assert.Len(t, feed.Trips, 5)
+}
+
+func TestActiveOn(t *testing.T) {
+	feed := loadTestFeed(t)
+	trip := feed.Trips["T1"]
+	berlin := feed.Location
+	assert.True(t, feed.ActiveOn(trip, time.Date(2023, 3, 3, 10, 0, 0, 0, berlin)))
+	// saturday
+	assert.False(t, feed.ActiveOn(trip, time.Date(2023, 3, 4, 10, 0, 0, 0, berlin)))
+	// removed on a holiday, added on a saturday
+	assert.False(t, feed.ActiveOn(trip, time.Date(2023, 5, 1, 10, 0, 0, 0, berlin)))
+	assert.True(t, feed.ActiveOn(trip, time.Date(2023, 5, 6, 10, 0, 0, 0, berlin)))
+	// the date is taken in the timezone of the feed, these are friday and saturday in Berlin
+	assert.True(t, feed.ActiveOn(trip, time.Date(2023, 3, 2, 23, 30, 0, 0, time.UTC)))
+	assert.False(t, feed.ActiveOn(trip, time.Date(2023, 3, 3, 23, 30, 0, 0, time.UTC)))
+}
+
+func TestServiceDay(t *testing.T) {
+	feed := loadTestFeed(t)
+	berlin := feed.Location
+	assert.Equal(t, time.Date(2023, 3, 3, 0, 0, 0, 0, berlin), feed.ServiceDay(time.Date(2023, 3, 3, 10, 0, 0, 0, berlin)))
+	// on the day the clocks go forward, the times are relative to 23:00 of the day before
+	assert.True(t, time.Date(2023, 3, 25, 23, 0, 0, 0, berlin).Equal(feed.ServiceDay(time.Date(2023, 3, 26, 10, 0, 0, 0, berlin))))
+}
+
+func TestParseTime(t *testing.T) {
+	d, err := parseTime("25:10:30")
+	assert.NoError(t, err)
+	assert.Equal(t, 25*time.Hour+10*time.Minute+30*time.Second, d)
+	d, err = parseTime("")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(-1), d)
+	_, err = parseTime("10:10")
+	assert.Error(t, err)
+}
@@ -0,0 +1,73 @@
+package gtfs
+
+import (
+	"math"
+	"trk/internal/lib/types"
+)
+
+const earthRadius = 6371000.0
+
+// distance returns the great circle distance between a and b in meters
+func distance(a, b types.Location) float64 {
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// polyline is the path of a trip with the distance from its start to every point
+type polyline struct {
+	points []types.Location
+	along  []float64
+}
+
+func newPolyline(points []types.Location) *polyline {
+	l := &polyline{points: points, along: make([]float64, len(points))}
+	for i := 1; i < len(points); i++ {
+		l.along[i] = l.along[i-1] + distance(points[i-1], points[i])
+	}
+	return l
+}
+
+// length returns the distance from the first to the last point in meters
+func (l *polyline) length() float64 {
+	if len(l.along) == 0 {
+		return 0
+	}
+	return l.along[len(l.along)-1]
+}
+
+// project returns the position on the line closest to p, as the distance from the start
+// of the line, and how far p is off the line. Only the part of the line after from is considered.
+func (l *polyline) project(p types.Location, from float64) (along, off float64) {
+	off = math.Inf(1)
+	if len(l.points) == 1 {
+		return 0, distance(p, l.points[0])
+	}
+	for i := 1; i < len(l.points); i++ {
+		if l.along[i] < from {
+			continue
+		}
+		a, b := l.points[i-1], l.points[i]
+		// a local flat projection around a is precise enough for the short segments of a shape
+		scale := math.Cos(a.Latitude*math.Pi/180) * earthRadius * math.Pi / 180
+		bx, by := (b.Longitude-a.Longitude)*scale, (b.Latitude-a.Latitude)*earthRadius*math.Pi/180
+		px, py := (p.Longitude-a.Longitude)*scale, (p.Latitude-a.Latitude)*earthRadius*math.Pi/180
+		t := 0.0
+		if length := bx*bx + by*by; length > 0 {
+			t = math.Max(0, math.Min(1, (px*bx+py*by)/length))
+		}
+		segmentAlong := l.along[i-1] + t*(l.along[i]-l.along[i-1])
+		if segmentAlong < from {
+			segmentAlong = from
+			t = (from - l.along[i-1]) / (l.along[i] - l.along[i-1])
+		}
+		d := math.Hypot(px-t*bx, py-t*by)
+		if d < off {
+			along, off = segmentAlong, d
+		}
+	}
+	return along, off
+}
@@ -0,0 +1,351 @@
+package gtfs
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"trk/internal/lib/types"
+)
+
+// Matcher finds the trip of the feed which fits the recent positions of the train best.
+// For every position the delay is estimated from where the train is on the path of a trip
+// and where it should be according to the schedule. The trip with the most positions on its
+// path and consistent delays wins.
+type Matcher struct {
+	Feed *Feed
+	// SearchRadius is the distance of the stops of the candidate trips from the latest position in meters
+	SearchRadius float64
+	// MaxDistance is how far a position may be off the shape of a trip in meters
+	MaxDistance float64
+	// MaxDistanceWithoutShape is the same for trips without a shape, whose path is guessed from the stops
+	MaxDistanceWithoutShape float64
+	// StationRadius is the distance up to which a position counts as being at a stop
+	StationRadius float64
+	MaxDelay      time.Duration
+	MaxEarly      time.Duration
+	// Tolerance is how much the delays of the positions may differ from each other
+	Tolerance time.Duration
+	// TraceLength is how long positions are kept
+	TraceLength time.Duration
+	// MinPoints is the number of positions on the path of a trip needed to match it
+	MinPoints int
+	// RailOnly ignores buses, trams and ferries
+	RailOnly bool
+
+	trace      []point
+	mu         sync.Mutex
+	geometries map[*Trip]*geometry
+}
+
+type point struct {
+	location types.Location
+	time     time.Time
+}
+
+// geometry is the path of a trip and where its stops are on it
+type geometry struct {
+	line     *polyline
+	stops    []float64
+	hasShape bool
+}
+
+func NewMatcher(feed *Feed) *Matcher {
+	return &Matcher{
+		Feed:                    feed,
+		SearchRadius:            30000,
+		MaxDistance:             300,
+		MaxDistanceWithoutShape: 3000,
+		StationRadius:           300,
+		MaxDelay:                3 * time.Hour,
+		MaxEarly:                3 * time.Minute,
+		Tolerance:               3 * time.Minute,
+		TraceLength:             10 * time.Minute,
+		MinPoints:               5,
+		RailOnly:                true,
+		geometries:              map[*Trip]*geometry{},
+	}
+}
+
+// Add adds a position of the train at t to the trace
+func (m *Matcher) Add(location types.Location, t time.Time) {
+	if n := len(m.trace); n > 0 && !t.After(m.trace[n-1].time) {
+		return
+	}
+	m.trace = append(m.trace, point{location: location, time: t})
+	start := 0
+	for start < len(m.trace) && m.trace[start].time.Before(t.Add(-m.TraceLength)) {
+		start++
+	}
+	m.trace = m.trace[start:]
+}
+
+// Reset forgets the trace, e.g. after a new ride started
+func (m *Matcher) Reset() {
+	m.trace = nil
+}
+
+// Match is a trip identified from the trace
+type Match struct {
+	Trip *Trip
+	// ServiceDay is the time the stop times of the trip are relative to
+	ServiceDay time.Time
+	// Delay is the delay estimated from the latest positions
+	Delay time.Duration
+	// Progress is how far the train is from the start of the trip in meters
+	Progress float64
+
+	// points is the number of positions matching the trip
+	points        int
+	stops         []float64
+	stationRadius float64
+}
+
+// Match returns the trip fitting the trace best, false if none fits
+func (m *Matcher) Match() (*Match, bool) {
+	if len(m.trace) < m.MinPoints {
+		return nil, false
+	}
+	latest := m.trace[len(m.trace)-1]
+	var best *Match
+	seen := map[*Trip]bool{}
+	for _, stop := range m.Feed.StopsNear(latest.location, m.SearchRadius) {
+		for _, trip := range m.Feed.TripsAt(stop) {
+			if seen[trip] || len(trip.StopTimes) < 2 || (m.RailOnly && !trip.Route.IsRail()) {
+				continue
+			}
+			seen[trip] = true
+			// trips running past midnight started on the day before
+			for _, date := range []time.Time{latest.time.AddDate(0, 0, -1), latest.time} {
+				if !m.Feed.ActiveOn(trip, date) {
+					continue
+				}
+				serviceDay := m.Feed.ServiceDay(date)
+				start := serviceDay.Add(trip.StopTimes[0].Departure)
+				end := serviceDay.Add(trip.StopTimes[len(trip.StopTimes)-1].Arrival)
+				if latest.time.Before(start.Add(-m.MaxEarly)) || latest.time.After(end.Add(m.MaxDelay)) {
+					continue
+				}
+				if match, ok := m.score(trip, serviceDay); ok && better(match, best) {
+					best = match
+				}
+			}
+		}
+	}
+	return best, best != nil
+}
+
+// better returns true if a has more matching positions than b or, if equal, a smaller delay
+func better(a, b *Match) bool {
+	if b == nil || a.points != b.points {
+		return b == nil || a.points > b.points
+	}
+	return absDuration(a.Delay) < absDuration(b.Delay)
+}
+
+// score matches the trace to trip on the service day, it fails if too few positions are on
+// the path of the trip, the train moves against its direction or the delays are inconsistent
+func (m *Matcher) score(trip *Trip, serviceDay time.Time) (*Match, bool) {
+	g := m.geometry(trip)
+	maxDistance := m.MaxDistance
+	if !g.hasShape {
+		maxDistance = m.MaxDistanceWithoutShape
+	}
+	var alongs []float64
+	var delays []time.Duration
+	for _, p := range m.trace {
+		along, off := g.line.project(p.location, 0)
+		if off > maxDistance {
+			continue
+		}
+		alongs = append(alongs, along)
+		delays = append(delays, m.delayAt(trip, g, serviceDay, along, p.time))
+	}
+	if len(delays) < m.MinPoints {
+		return nil, false
+	}
+	if alongs[len(alongs)-1] < alongs[0]-m.StationRadius {
+		return nil, false
+	}
+	median := medianDuration(delays)
+	if median < -m.MaxEarly || median > m.MaxDelay {
+		return nil, false
+	}
+	points := 0
+	for _, delay := range delays {
+		if absDuration(delay-median) <= m.Tolerance {
+			points++
+		}
+	}
+	if points < m.MinPoints {
+		return nil, false
+	}
+	latest := delays
+	if len(latest) > 3 {
+		latest = latest[len(latest)-3:]
+	}
+	return &Match{
+		Trip:          trip,
+		ServiceDay:    serviceDay,
+		Delay:         medianDuration(latest),
+		Progress:      alongs[len(alongs)-1],
+		points:        points,
+		stops:         g.stops,
+		stationRadius: m.StationRadius,
+	}, true
+}
+
+// delayAt estimates the delay of the train being along meters into trip at t
+func (m *Matcher) delayAt(trip *Trip, g *geometry, serviceDay time.Time, along float64, t time.Time) time.Duration {
+	stopTimes := trip.StopTimes
+	last := len(stopTimes) - 1
+	for i, stopTime := range stopTimes {
+		if math.Abs(along-g.stops[i]) > m.StationRadius {
+			continue
+		}
+		// standing at a stop is on time between the arrival and the departure
+		arrival, departure := serviceDay.Add(stopTime.Arrival), serviceDay.Add(stopTime.Departure)
+		switch {
+		case t.Before(arrival) && i > 0:
+			return t.Sub(arrival)
+		case t.After(departure) && i < last:
+			return t.Sub(departure)
+		default:
+			return 0
+		}
+	}
+	if along < g.stops[0] {
+		return maxDuration(0, t.Sub(serviceDay.Add(stopTimes[0].Departure)))
+	}
+	for i := 0; i < last; i++ {
+		if along > g.stops[i+1] {
+			continue
+		}
+		// between two stops the train is assumed to run at a constant speed
+		departure := serviceDay.Add(stopTimes[i].Departure)
+		arrival := serviceDay.Add(stopTimes[i+1].Arrival)
+		fraction := 0.0
+		if length := g.stops[i+1] - g.stops[i]; length > 0 {
+			fraction = (along - g.stops[i]) / length
+		}
+		return t.Sub(departure.Add(time.Duration(fraction * float64(arrival.Sub(departure)))))
+	}
+	return minDuration(0, t.Sub(serviceDay.Add(stopTimes[last].Arrival)))
+}
+
+// geometry returns the path of trip, its shape or the line through its stops
+func (m *Matcher) geometry(trip *Trip) *geometry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if g, ok := m.geometries[trip]; ok {
+		return g
+	}
+	g := &geometry{stops: make([]float64, len(trip.StopTimes))}
+	if shape := m.Feed.Shapes[trip.ShapeId]; len(shape) >= 2 {
+		g.line = newPolyline(shape)
+		g.hasShape = true
+		from := 0.0
+		for i, stopTime := range trip.StopTimes {
+			from, _ = g.line.project(stopTime.Stop.Location, from)
+			g.stops[i] = from
+		}
+	} else {
+		points := make([]types.Location, len(trip.StopTimes))
+		for i, stopTime := range trip.StopTimes {
+			points[i] = stopTime.Stop.Location
+		}
+		g.line = newPolyline(points)
+		copy(g.stops, g.line.along)
+	}
+	m.geometries[trip] = g
+	return g
+}
+
+// Train returns the train of the matched trip, e.g. RE 5
+func (m *Match) Train() types.Train {
+	route := m.Trip.Route
+	name := route.ShortName
+	if name == "" {
+		name = m.Trip.ShortName
+	}
+	if name == "" {
+		name = route.LongName
+	}
+	train := types.Train{
+		Id:            "gtfs:" + m.Trip.Id,
+		DisplayName:   name,
+		LookupString:  name,
+		Line:          name,
+		SeriesDisplay: route.LongName,
+	}
+	if fields := strings.Fields(name); len(fields) >= 2 {
+		train.Type = fields[0]
+		train.Line = strings.Join(fields[1:], " ")
+	}
+	if train.SeriesDisplay == "" || train.SeriesDisplay == name {
+		train.SeriesDisplay = "train"
+	}
+	return train
+}
+
+// Stops returns the stops of the matched trip. The estimated delay is added to the stops which
+// have not been passed yet, stops behind the train are passed.
+func (m *Match) Stops() []types.Stop {
+	stopTimes := m.Trip.StopTimes
+	stops := make([]types.Stop, 0, len(stopTimes))
+	for i, stopTime := range stopTimes {
+		stop := types.Stop{
+			Id:       stopTime.Stop.Id,
+			Name:     stopTime.Stop.Name,
+			Location: stopTime.Stop.Location,
+			Track:    stopTime.Stop.Platform,
+			Passed:   m.stops[i]+m.stationRadius < m.Progress,
+		}
+		if i > 0 {
+			stop.ScheduledArrivalTime = m.ServiceDay.Add(stopTime.Arrival)
+			stop.ArrivalTime = stop.ScheduledArrivalTime
+		}
+		if i < len(stopTimes)-1 {
+			stop.ScheduledDepartureTime = m.ServiceDay.Add(stopTime.Departure)
+			stop.DepartureTime = stop.ScheduledDepartureTime
+		}
+		if !stop.Passed && m.Delay > 0 {
+			if !stop.ArrivalTime.IsZero() {
+				stop.ArrivalTime = stop.ArrivalTime.Add(m.Delay)
+			}
+			if !stop.DepartureTime.IsZero() {
+				stop.DepartureTime = stop.DepartureTime.Add(m.Delay)
+			}
+		}
+		stops = append(stops, stop)
+	}
+	return stops
+}
+
+func medianDuration(values []time.Duration) time.Duration {
+	sorted := append([]time.Duration(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
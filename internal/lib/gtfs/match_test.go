@@ -0,0 +1,135 @@
+package gtfs
+
+import (
+	"testing"
+	"time"
+	"trk/internal/lib/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// eastbound returns the position of a train following the times of T1, which runs
+// from Aheim at 10.00° east through Bstadt and Cdorf to Dburg at 10.30° east on the 50th parallel.
+// scheduled is the time of the schedule, i.e. the actual time minus the delay.
+func eastbound(scheduled time.Duration) types.Location {
+	stops := []struct {
+		lon                float64
+		arrival, departure time.Duration
+	}{
+		{10.00, 0, 0},
+		{10.10, 6 * time.Minute, 7 * time.Minute},
+		{10.20, 13 * time.Minute, 14 * time.Minute},
+		{10.30, 20 * time.Minute, 20 * time.Minute},
+	}
+	for i := 0; i < len(stops)-1; i++ {
+		if scheduled <= stops[i].departure {
+			return types.Location{Latitude: 50, Longitude: stops[i].lon}
+		}
+		if scheduled < stops[i+1].arrival {
+			fraction := float64(scheduled-stops[i].departure) / float64(stops[i+1].arrival-stops[i].departure)
+			return types.Location{Latitude: 50, Longitude: stops[i].lon + fraction*(stops[i+1].lon-stops[i].lon)}
+		}
+	}
+	return types.Location{Latitude: 50, Longitude: 10.30}
+}
+
+// addTrace adds a position every 20s from start to end to the matcher, of a train departing at departure
+func addTrace(m *Matcher, departure, start, end time.Time, delay time.Duration) {
+	for t := start; !t.After(end); t = t.Add(20 * time.Second) {
+		m.Add(eastbound(t.Sub(departure)-delay), t)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	feed := loadTestFeed(t)
+	berlin := feed.Location
+	m := NewMatcher(feed)
+	departure := time.Date(2023, 3, 3, 10, 0, 0, 0, berlin)
+	addTrace(m, departure, departure.Add(9*time.Minute), departure.Add(12*time.Minute), 3*time.Minute)
+
+	match, ok := m.Match()
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, "T1", match.Trip.Id)
+	assert.InDelta(t, float64(3*time.Minute), float64(match.Delay), float64(30*time.Second))
+
+	train := match.Train()
+	assert.Equal(t, "gtfs:T1", train.Id)
+	assert.Equal(t, "RE 1", train.DisplayName)
+	assert.Equal(t, "RE", train.Type)
+	assert.Equal(t, "1", train.Line)
+	assert.Equal(t, "Main-Express", train.SeriesDisplay)
+
+	stops := match.Stops()
+	if assert.Len(t, stops, 4) {
+		assert.True(t, stops[0].Passed)
+		assert.True(t, stops[1].Passed)
+		assert.Equal(t, time.Duration(0), stops[1].Delay())
+		next := types.Trip(stops).GetNextStop()
+		assert.Equal(t, "Cdorf", next.Name)
+		assert.True(t, next.ScheduledArrivalTime.Equal(time.Date(2023, 3, 3, 10, 13, 0, 0, berlin)))
+		assert.InDelta(t, float64(3*time.Minute), float64(next.Delay()), float64(30*time.Second))
+		assert.Equal(t, "5", stops[3].Track)
+		assert.True(t, stops[3].DepartureTime.IsZero())
+	}
+}
+
+func TestMatchNeedsEnoughPositions(t *testing.T) {
+	feed := loadTestFeed(t)
+	m := NewMatcher(feed)
+	departure := time.Date(2023, 3, 3, 10, 0, 0, 0, feed.Location)
+	addTrace(m, departure, departure.Add(9*time.Minute), departure.Add(10*time.Minute), 0)
+	_, ok := m.Match()
+	assert.False(t, ok)
+}
+
+func TestMatchOtherTrip(t *testing.T) {
+	feed := loadTestFeed(t)
+	m := NewMatcher(feed)
+	// the same positions an hour later fit T3
+	departure := time.Date(2023, 3, 3, 11, 0, 0, 0, feed.Location)
+	addTrace(m, departure, departure.Add(2*time.Minute), departure.Add(5*time.Minute), 0)
+	match, ok := m.Match()
+	if assert.True(t, ok) {
+		assert.Equal(t, "T3", match.Trip.Id)
+		assert.InDelta(t, 0, float64(match.Delay), float64(30*time.Second))
+	}
+}
+
+func TestMatchNotRunning(t *testing.T) {
+	feed := loadTestFeed(t)
+	m := NewMatcher(feed)
+	// no service on the holiday
+	departure := time.Date(2023, 5, 1, 10, 0, 0, 0, feed.Location)
+	addTrace(m, departure, departure.Add(2*time.Minute), departure.Add(5*time.Minute), 0)
+	_, ok := m.Match()
+	assert.False(t, ok)
+}
+
+func TestMatchPastMidnight(t *testing.T) {
+	feed := loadTestFeed(t)
+	m := NewMatcher(feed)
+	// T4 leaves on friday at 23:50 and arrives on saturday, when WD is not running
+	departure := time.Date(2023, 3, 3, 23, 50, 0, 0, feed.Location)
+	addTrace(m, departure, departure.Add(10*time.Minute), departure.Add(13*time.Minute), 2*time.Minute)
+	match, ok := m.Match()
+	if assert.True(t, ok) {
+		assert.Equal(t, "T4", match.Trip.Id)
+		assert.InDelta(t, float64(2*time.Minute), float64(match.Delay), float64(30*time.Second))
+		assert.True(t, match.ServiceDay.Equal(time.Date(2023, 3, 3, 0, 0, 0, 0, feed.Location)))
+	}
+}
+
+func TestTrace(t *testing.T) {
+	m := NewMatcher(nil)
+	start := time.Date(2023, 3, 3, 10, 0, 0, 0, time.UTC)
+	m.Add(types.Location{Latitude: 50, Longitude: 10}, start)
+	// out of order positions are dropped
+	m.Add(types.Location{Latitude: 50, Longitude: 10}, start.Add(-time.Second))
+	assert.Len(t, m.trace, 1)
+	m.Add(types.Location{Latitude: 50, Longitude: 10.1}, start.Add(m.TraceLength+time.Second))
+	assert.Len(t, m.trace, 1)
+	m.Reset()
+	assert.Empty(t, m.trace)
+}
@@ -0,0 +1,132 @@
+package gtfs
+
+import (
+	"context"
+	"sync"
+	"trk/internal/lib/provider"
+	"trk/internal/lib/types"
+)
+
+// Provider identifies the train of a provider which only knows the position, like gpsd.
+// As long as the wrapped provider knows the stops itself, its statuses are passed on unchanged.
+type Provider struct {
+	provider.Provider
+	Matcher *Matcher
+
+	mu    sync.Mutex
+	match *Match
+}
+
+// fallback is a wrapped provider.Fallback, it stays a fallback
+type fallback struct {
+	*Provider
+}
+
+func (fallback) Fallback() {}
+
+// bindable is a wrapped provider.Bindable, e.g. a portal without onward connections
+type bindable struct {
+	*Provider
+	provider.Bindable
+}
+
+// connections is a wrapped provider.ConnectionProvider
+type connections struct {
+	*Provider
+	provider.ConnectionProvider
+}
+
+// portal is a wrapped provider which is both Bindable and a ConnectionProvider
+type portal struct {
+	*Provider
+	provider.Bindable
+	provider.ConnectionProvider
+}
+
+// Wrap identifies the train of p with matcher. The wrapped provider keeps the optional
+// interfaces of p, i.e. provider.Fallback, provider.Bindable and provider.ConnectionProvider.
+func Wrap(p provider.Provider, matcher *Matcher) provider.Provider {
+	wrapped := &Provider{Provider: p, Matcher: matcher}
+	if _, ok := p.(provider.Fallback); ok {
+		// the fallbacks only know the position, they neither bind nor know connections
+		return fallback{wrapped}
+	}
+	b, canBind := p.(provider.Bindable)
+	c, hasConnections := p.(provider.ConnectionProvider)
+	switch {
+	case canBind && hasConnections:
+		return portal{Provider: wrapped, Bindable: b, ConnectionProvider: c}
+	case canBind:
+		return bindable{Provider: wrapped, Bindable: b}
+	case hasConnections:
+		return connections{Provider: wrapped, ConnectionProvider: c}
+	}
+	return wrapped
+}
+
+func (p *Provider) Run(ctx context.Context, statusChan chan types.Status) error {
+	p.Matcher.Reset()
+	p.setMatch(nil)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	positions := make(chan types.Status)
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Provider.Run(ctx, positions)
+	}()
+	for {
+		select {
+		case err := <-done:
+			return err
+		case status := <-positions:
+			select {
+			case statusChan <- p.identify(status):
+			case <-ctx.Done():
+				return <-done
+			}
+		}
+	}
+}
+
+// identify adds the train, the next stop and the delay of the matching trip to status
+func (p *Provider) identify(status types.Status) types.Status {
+	if len(p.Provider.GetStops()) > 0 {
+		return status
+	}
+	if !status.GPSLost {
+		p.Matcher.Add(status.Location, status.Timestamp)
+	}
+	match, ok := p.Matcher.Match()
+	if !ok {
+		p.setMatch(nil)
+		return status
+	}
+	p.setMatch(match)
+	status.Train = match.Train()
+	status.Delay = 0
+	status.NextStop = types.Stop{}
+	if next := types.Trip(match.Stops()).GetNextStop(); next != nil {
+		status.NextStop = *next
+		status.Delay = next.Delay()
+	}
+	return status
+}
+
+func (p *Provider) setMatch(match *Match) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.match = match
+}
+
+// GetStops returns the stops of the wrapped provider, the ones of the matching trip if it has none
+func (p *Provider) GetStops() []types.Stop {
+	if stops := p.Provider.GetStops(); len(stops) > 0 {
+		return stops
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.match == nil {
+		return nil
+	}
+	return p.match.Stops()
+}
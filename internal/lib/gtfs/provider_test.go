@@ -0,0 +1,136 @@
+package gtfs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"trk/internal/lib/netbind"
+	"trk/internal/lib/provider"
+	"trk/internal/lib/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errEndOfTrace = errors.New("end of trace")
+
+// traceProvider sends its statuses and knows nothing else, like gpsd
+type traceProvider struct {
+	statuses []types.Status
+}
+
+func (p *traceProvider) Probe() (bool, error) { return true, nil }
+
+func (p *traceProvider) Run(ctx context.Context, statusChan chan types.Status) error {
+	for _, status := range p.statuses {
+		select {
+		case statusChan <- status:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return errEndOfTrace
+}
+
+func (p *traceProvider) GetStops() []types.Stop     { return nil }
+func (p *traceProvider) GetTrainInfo(string) string { return "" }
+func (p *traceProvider) GetSSIDs() []string         { return nil }
+func (p *traceProvider) Fallback()                  {}
+
+func TestProvider(t *testing.T) {
+	feed := loadTestFeed(t)
+	departure := time.Date(2023, 3, 3, 10, 0, 0, 0, feed.Location)
+	trace := &traceProvider{}
+	for at := departure.Add(9 * time.Minute); !at.After(departure.Add(12 * time.Minute)); at = at.Add(20 * time.Second) {
+		trace.statuses = append(trace.statuses, types.Status{
+			Train:     types.Train{Id: "gpsd"},
+			Location:  eastbound(at.Sub(departure) - 3*time.Minute),
+			Timestamp: at,
+		})
+	}
+
+	p := Wrap(trace, NewMatcher(feed))
+	_, ok := p.(provider.Fallback)
+	assert.True(t, ok)
+
+	statusChan := make(chan types.Status)
+	done := make(chan error)
+	go func() {
+		done <- p.Run(context.Background(), statusChan)
+	}()
+	var statuses []types.Status
+	for {
+		select {
+		case status := <-statusChan:
+			statuses = append(statuses, status)
+			continue
+		case err := <-done:
+			assert.ErrorIs(t, err, errEndOfTrace)
+		}
+		break
+	}
+
+	if !assert.Len(t, statuses, len(trace.statuses)) {
+		return
+	}
+	// the first positions are not enough for a match
+	assert.Equal(t, "gpsd", statuses[0].Train.Id)
+	last := statuses[len(statuses)-1]
+	assert.Equal(t, "RE 1", last.Train.DisplayName)
+	assert.Equal(t, "Cdorf", last.NextStop.Name)
+	assert.InDelta(t, float64(3*time.Minute), float64(last.Delay), float64(30*time.Second))
+	assert.Len(t, p.GetStops(), 4)
+}
+
+// pluginProvider is a provider without any of the optional interfaces
+type pluginProvider struct{}
+
+func (pluginProvider) Probe() (bool, error) { return true, nil }
+func (pluginProvider) Run(ctx context.Context, statusChan chan types.Status) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+func (pluginProvider) GetStops() []types.Stop     { return nil }
+func (pluginProvider) GetTrainInfo(string) string { return "" }
+func (pluginProvider) GetSSIDs() []string         { return nil }
+
+// portalProvider is a portal without trip data, which can be bound and knows connections
+type portalProvider struct {
+	pluginProvider
+	binding netbind.Binding
+}
+
+func (p *portalProvider) Bind(binding netbind.Binding) { p.binding = binding }
+
+func (p *portalProvider) GetConnections(ctx context.Context, stopId string) ([]types.Connection, error) {
+	return []types.Connection{{Train: types.Train{DisplayName: "RB 26"}}}, nil
+}
+
+func TestWrapKeepsInterfaces(t *testing.T) {
+	feed := loadTestFeed(t)
+	inner := &portalProvider{}
+	p := Wrap(inner, NewMatcher(feed))
+	_, ok := p.(provider.Fallback)
+	assert.False(t, ok)
+
+	bindable, ok := p.(provider.Bindable)
+	if assert.True(t, ok) {
+		bindable.Bind(netbind.Binding{Interface: "wlp3s0"})
+		assert.Equal(t, "wlp3s0", inner.binding.Interface)
+	}
+	connectionProvider, ok := p.(provider.ConnectionProvider)
+	if assert.True(t, ok) {
+		connections, err := connectionProvider.GetConnections(context.Background(), "8000261")
+		assert.NoError(t, err)
+		assert.Len(t, connections, 1)
+	}
+
+	// a plugin has neither
+	p = Wrap(&pluginProvider{}, NewMatcher(feed))
+	_, ok = p.(provider.Bindable)
+	assert.False(t, ok)
+	_, ok = p.(provider.ConnectionProvider)
+	assert.False(t, ok)
+	_, ok = p.(provider.Fallback)
+	assert.False(t, ok)
+}
@@ -0,0 +1,41 @@
+package helper
+
+import (
+	"fmt"
+	"math"
+	"time"
+	"trk/internal/lib/types"
+)
+
+var connectivityNames = map[string]string{
+	types.ConnectivityHigh:     "good",
+	types.ConnectivityWeak:     "weak",
+	types.ConnectivityUnstable: "unstable",
+	types.ConnectivityNone:     "offline",
+	types.ConnectivityUnknown:  "unknown",
+}
+
+// ConnectivityName returns a readable name of a connectivity state, e.g. "weak" for WEAK
+func ConnectivityName(state string) string {
+	if name, ok := connectivityNames[state]; ok {
+		return name
+	}
+	return state
+}
+
+// FormatMinutes formats a duration in whole minutes rounded up, at least 1 min
+func FormatMinutes(d time.Duration) string {
+	return fmt.Sprintf("%d min", int(math.Max(1, math.Ceil(d.Minutes()))))
+}
+
+// FormatConnectivity describes the current connectivity and its forecast, e.g. "weak (good in 8 min)"
+func FormatConnectivity(c types.Connectivity) string {
+	if c.CurrentState == "" {
+		return ConnectivityName(types.ConnectivityUnknown)
+	}
+	text := ConnectivityName(c.CurrentState)
+	if c.NextState != "" && c.NextState != c.CurrentState && c.NextIn > 0 {
+		text += fmt.Sprintf(" (%s in %s)", ConnectivityName(c.NextState), FormatMinutes(c.NextIn))
+	}
+	return text
+}
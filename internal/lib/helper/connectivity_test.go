@@ -0,0 +1,16 @@
+package helper
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	"trk/internal/lib/types"
+)
+
+func TestFormatConnectivity(t *testing.T) {
+	assert.Equal(t, "good", FormatConnectivity(types.Connectivity{CurrentState: "HIGH", NextState: "HIGH"}))
+	assert.Equal(t, "weak (good in 8 min)", FormatConnectivity(types.Connectivity{CurrentState: "WEAK", NextState: "HIGH", NextIn: 7*time.Minute + 10*time.Second}))
+	assert.Equal(t, "good (offline in 1 min)", FormatConnectivity(types.Connectivity{CurrentState: "HIGH", NextState: "NO_INTERNET", NextIn: 20 * time.Second}))
+	assert.Equal(t, "unknown", FormatConnectivity(types.Connectivity{}))
+	assert.Equal(t, "MIDDLE", FormatConnectivity(types.Connectivity{CurrentState: "MIDDLE"}))
+}
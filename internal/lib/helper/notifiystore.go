@@ -22,6 +22,13 @@ const (
 	TrainNotification
 	StationNotification
 	DelayNotification
+	PlatformNotification
+	AlarmNotification
+	ConnectionNotification
+	ConnectivityNotification
+	// DestinationPlatformNotification is kept apart from PlatformNotification, so a platform change
+	// of the next stop doesn't replace the one of the destination
+	DestinationPlatformNotification
 )
 
 func (n *NotifyStore) AddNotification(notificationType NotificationType, id uint32, data any) {
@@ -0,0 +1,49 @@
+package helper
+
+import (
+	"fmt"
+	"math"
+	"time"
+	"trk/internal/lib/types"
+)
+
+// FormatDelay formats a delay in whole minutes, e.g. "+12 min"
+func FormatDelay(delay time.Duration) string {
+	return fmt.Sprintf("%+d min", int(math.Round(delay.Minutes())))
+}
+
+// FormatTimetable formats a time like the departure boards do, the actual time followed
+// by the delay in minutes, e.g. "14:32 (+7)". The delay is left out if it is zero or unknown.
+func FormatTimetable(actual, scheduled time.Time) string {
+	if actual.IsZero() {
+		actual = scheduled
+	}
+	if actual.IsZero() {
+		return ""
+	}
+	text := actual.Format("15:04")
+	if scheduled.IsZero() {
+		return text
+	}
+	delay := int(math.Round(actual.Sub(scheduled).Minutes()))
+	if delay == 0 {
+		return text
+	}
+	return fmt.Sprintf("%s (%+d)", text, delay)
+}
+
+// FormatConnection describes an onward train, e.g. "RE 1 → Salzburg Hbf 13:58 (+3) track 26"
+func FormatConnection(connection types.Connection) string {
+	text := fmt.Sprintf("%s → %s %s", connection.Train.DisplayName, connection.Destination,
+		FormatTimetable(connection.DepartureTime, connection.ScheduledDepartureTime))
+	if connection.Cancelled {
+		return text + " cancelled"
+	}
+	if connection.Track != "" {
+		text += " track " + connection.Track
+		if connection.ScheduledTrack != "" && connection.Track != connection.ScheduledTrack {
+			text += " instead of " + connection.ScheduledTrack
+		}
+	}
+	return text
+}
@@ -0,0 +1,40 @@
+package helper
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	"trk/internal/lib/types"
+)
+
+func TestFormatDelay(t *testing.T) {
+	assert.Equal(t, "+12 min", FormatDelay(12*time.Minute+10*time.Second))
+	assert.Equal(t, "-1 min", FormatDelay(-time.Minute))
+}
+
+func TestFormatTimetable(t *testing.T) {
+	scheduled := time.Date(2023, 2, 25, 14, 25, 0, 0, time.Local)
+	assert.Equal(t, "14:32 (+7)", FormatTimetable(scheduled.Add(7*time.Minute), scheduled))
+	assert.Equal(t, "14:23 (-2)", FormatTimetable(scheduled.Add(-2*time.Minute), scheduled))
+	assert.Equal(t, "14:25", FormatTimetable(scheduled.Add(20*time.Second), scheduled))
+	assert.Equal(t, "14:25", FormatTimetable(time.Time{}, scheduled))
+	assert.Equal(t, "14:25", FormatTimetable(scheduled, time.Time{}))
+	assert.Equal(t, "", FormatTimetable(time.Time{}, time.Time{}))
+}
+
+func TestFormatConnection(t *testing.T) {
+	scheduled := time.Date(2023, 2, 25, 13, 58, 0, 0, time.Local)
+	connection := types.Connection{
+		Train:                  types.Train{DisplayName: "RE 1"},
+		Destination:            "Salzburg Hbf",
+		ScheduledDepartureTime: scheduled,
+		DepartureTime:          scheduled.Add(3 * time.Minute),
+		Track:                  "26",
+		ScheduledTrack:         "26",
+	}
+	assert.Equal(t, "RE 1 → Salzburg Hbf 14:01 (+3) track 26", FormatConnection(connection))
+	connection.Track = "24"
+	assert.Equal(t, "RE 1 → Salzburg Hbf 14:01 (+3) track 24 instead of 26", FormatConnection(connection))
+	connection.Cancelled = true
+	assert.Equal(t, "RE 1 → Salzburg Hbf 14:01 (+3) cancelled", FormatConnection(connection))
+}
@@ -56,14 +56,21 @@ func (v *VagonWebLookup) GetTrainLink(searchterm string) (string, error) {
 	searchresults := make([]SearchResult, 0)
 	err = dec.Decode(&searchresults)
 	if len(searchresults) > 0 {
-		m := make(url.Values)
-		m.Set("zeme", searchresults[0].Zeme)
-		m.Set("kategorie", searchresults[0].Druh)
-		m.Set("cislo", searchresults[0].Cislo)
-		v.trainURL.RawQuery = m.Encode()
-		return v.trainURL.String(), nil
+		return v.TrainLink(searchresults[0].Zeme, searchresults[0].Druh, searchresults[0].Cislo), nil
 	}
 
 	return "", errors.New("nothing found")
 
 }
+
+// TrainLink returns the url of the page of a train whose operator, category and number are known,
+// e.g. "CD", "RJ", "1021"
+func (v *VagonWebLookup) TrainLink(operator, category, number string) string {
+	trainURL := *v.trainURL
+	m := make(url.Values)
+	m.Set("zeme", operator)
+	m.Set("kategorie", category)
+	m.Set("cislo", number)
+	trainURL.RawQuery = m.Encode()
+	return trainURL.String()
+}
@@ -0,0 +1,13 @@
+package lookup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrainLink(t *testing.T) {
+	v := NewVagonWebLookup()
+	assert.Equal(t, "https://www.vagonweb.cz/razeni/vlak.php?cislo=1021&kategorie=RJ&zeme=CD", v.TrainLink("CD", "RJ", "1021"))
+	assert.Equal(t, "https://www.vagonweb.cz/razeni/vlak.php?cislo=73&kategorie=EC&zeme=CD", v.TrainLink("CD", "EC", "73"))
+}
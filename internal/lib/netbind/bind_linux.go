@@ -0,0 +1,21 @@
+//go:build linux
+
+package netbind
+
+import (
+	"syscall"
+)
+
+// bindToDevice binds sockets to the interface with SO_BINDTODEVICE
+func bindToDevice(iface string) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var bindErr error
+		err := c.Control(func(fd uintptr) {
+			bindErr = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, iface)
+		})
+		if err != nil {
+			return err
+		}
+		return bindErr
+	}
+}
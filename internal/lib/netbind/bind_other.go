@@ -0,0 +1,15 @@
+//go:build !linux
+
+package netbind
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// bindToDevice fails, binding sockets to an interface is only implemented on linux
+func bindToDevice(iface string) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		return fmt.Errorf("binding to %s is not supported on this platform", iface)
+	}
+}
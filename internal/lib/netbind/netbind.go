@@ -0,0 +1,167 @@
+// Package netbind sends DNS lookups and HTTP requests through one network interface,
+// so the portals can be reached while a VPN takes over the default route and DNS.
+package netbind
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Binding is the interface and the DNS servers to use, the zero value uses the system defaults
+type Binding struct {
+	// Interface is the name of the network interface, e.g. wlp3s0
+	Interface string
+	// DNS are the addresses of the DNS servers, the port defaults to 53
+	DNS []string
+}
+
+// IsZero returns true if the binding does not change anything
+func (b Binding) IsZero() bool {
+	return b.Interface == "" && len(b.DNS) == 0
+}
+
+// Dialer returns a dialer whose connections are bound to Interface and which resolves via DNS
+func (b Binding) Dialer() *net.Dialer {
+	dialer := b.bareDialer()
+	dialer.Resolver = b.Resolver()
+	return dialer
+}
+
+func (b Binding) bareDialer() *net.Dialer {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if b.Interface != "" {
+		dialer.Control = bindToDevice(b.Interface)
+	}
+	return dialer
+}
+
+// Resolver returns a resolver asking the DNS servers, the queries are sent through Interface
+func (b Binding) Resolver() *net.Resolver {
+	if len(b.DNS) == 0 {
+		if b.Interface == "" {
+			return net.DefaultResolver
+		}
+		// the system's servers, but reached through the interface
+		return &net.Resolver{PreferGo: true, Dial: b.bareDialer().DialContext}
+	}
+	r := &rotation{}
+	for _, server := range b.DNS {
+		r.servers = append(r.servers, withDefaultPort(server))
+	}
+	dialer := b.bareDialer()
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return r.dial(ctx, network, dialer.DialContext)
+		},
+	}
+}
+
+// rotation tries the DNS servers in turn. The resolver dials concurrently, e.g. for A and AAAA.
+// A server counts as failed if it cannot be dialed, if a query to it times out or if it answers
+// SERVFAIL, the resolver then dials again for its next attempt and gets the next server.
+type rotation struct {
+	servers []string
+
+	mu   sync.Mutex
+	next int
+}
+
+// dial connects to the first server that can be dialed, starting with the one after the last failure
+func (r *rotation) dial(ctx context.Context, network string, dial func(ctx context.Context, network, address string) (net.Conn, error)) (net.Conn, error) {
+	r.mu.Lock()
+	start := r.next
+	r.mu.Unlock()
+	var err error
+	for i := 0; i < len(r.servers); i++ {
+		index := (start + i) % len(r.servers)
+		var conn net.Conn
+		conn, err = dial(ctx, network, r.servers[index])
+		if err == nil {
+			return r.wrap(conn, index), nil
+		}
+		if ctx.Err() != nil {
+			// the lookup was given up, the server is not to blame
+			return nil, err
+		}
+		r.failed(index)
+	}
+	return nil, err
+}
+
+// failed moves on to the server after index, unless a concurrent query already did
+func (r *rotation) failed(index int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.next == index {
+		r.next = (index + 1) % len(r.servers)
+	}
+}
+
+// wrap reports the failures of conn to the server at index, the resolver tells UDP by net.PacketConn
+func (r *rotation) wrap(conn net.Conn, index int) net.Conn {
+	c := &serverConn{Conn: conn, rotation: r, index: index}
+	if packetConn, ok := conn.(net.PacketConn); ok {
+		c.packet = true
+		return &serverPacketConn{serverConn: c, packetConn: packetConn}
+	}
+	return c
+}
+
+// rcodeServerFailure is the SERVFAIL response code of a DNS message
+const rcodeServerFailure = 2
+
+// serverConn is the connection to the server at index, it reports timeouts and SERVFAIL answers to the rotation
+type serverConn struct {
+	net.Conn
+	rotation *rotation
+	index    int
+	// packet is set for UDP, where every read returns a whole DNS message
+	packet bool
+}
+
+func (c *serverConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		c.rotation.failed(c.index)
+	}
+	// the response code is in the low bits of the fourth byte of the header
+	if err == nil && c.packet && n >= 4 && b[3]&0x0f == rcodeServerFailure {
+		c.rotation.failed(c.index)
+	}
+	return n, err
+}
+
+type serverPacketConn struct {
+	*serverConn
+	packetConn net.PacketConn
+}
+
+func (c *serverPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	return c.packetConn.ReadFrom(b)
+}
+
+func (c *serverPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return c.packetConn.WriteTo(b, addr)
+}
+
+// Transport returns an HTTP transport using Dialer
+func (b Binding) Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = b.Dialer().DialContext
+	// a proxy would leave the interface
+	transport.Proxy = nil
+	return transport
+}
+
+func withDefaultPort(server string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(server, "53")
+}
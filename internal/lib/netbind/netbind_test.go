@@ -0,0 +1,197 @@
+package netbind
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolverAsksConfiguredServer(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer server.Close()
+	received := make(chan struct{}, 1)
+	go func() {
+		buf := make([]byte, 512)
+		if _, _, err := server.ReadFrom(buf); err == nil {
+			received <- struct{}{}
+		}
+	}()
+
+	resolver := Binding{DNS: []string{server.LocalAddr().String()}}.Resolver()
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	// the fake server never answers, only the query is of interest
+	_, _ = resolver.LookupIPAddr(ctx, "iceportal.de")
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("the configured DNS server was not asked")
+	}
+}
+
+// fakeDNS starts a DNS server on loopback which passes every query to queries and answers
+// with reply, a nil reply never answers
+func fakeDNS(t *testing.T, reply func(query []byte) []byte) (string, <-chan []byte) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	queries := make(chan []byte, 16)
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := server.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			query := append([]byte(nil), buf[:n]...)
+			select {
+			case queries <- query:
+			default:
+			}
+			if reply != nil {
+				_, _ = server.WriteTo(reply(query), addr)
+			}
+		}
+	}()
+	return server.LocalAddr().String(), queries
+}
+
+func asked(queries <-chan []byte) bool {
+	select {
+	case <-queries:
+		return true
+	case <-time.After(time.Second):
+		return false
+	}
+}
+
+func TestRotationSkipsSilentServer(t *testing.T) {
+	silent, silentQueries := fakeDNS(t, nil)
+	other, otherQueries := fakeDNS(t, nil)
+	r := &rotation{servers: []string{silent, other}}
+	dialer := &net.Dialer{}
+
+	conn, err := r.dial(context.Background(), "udp", dialer.DialContext)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, isPacketConn := conn.(net.PacketConn)
+	assert.True(t, isPacketConn, "the resolver needs a net.PacketConn for UDP")
+	_, _ = conn.Write([]byte("query"))
+	assert.True(t, asked(silentQueries))
+	_ = conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, err = conn.Read(make([]byte, 512))
+	assert.Error(t, err)
+	conn.Close()
+
+	// the query timed out, the resolver's next attempt goes to the second server
+	conn, err = r.dial(context.Background(), "udp", dialer.DialContext)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	_, _ = conn.Write([]byte("query"))
+	assert.True(t, asked(otherQueries), "the second DNS server was not asked")
+}
+
+func TestResolverSkipsServerFailure(t *testing.T) {
+	failing, failingQueries := fakeDNS(t, func(query []byte) []byte {
+		// the query itself with the response flag and SERVFAIL
+		response := append([]byte(nil), query...)
+		response[2] |= 0x80
+		response[3] = response[3]&0xf0 | rcodeServerFailure
+		return response
+	})
+	other, otherQueries := fakeDNS(t, nil)
+	resolver := Binding{DNS: []string{failing, other}}.Resolver()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, _ = resolver.LookupIPAddr(ctx, "iceportal.de")
+	assert.True(t, asked(failingQueries))
+	assert.True(t, asked(otherQueries), "the second DNS server was not asked after SERVFAIL")
+}
+
+func TestDefaults(t *testing.T) {
+	assert.True(t, Binding{}.IsZero())
+	assert.Same(t, net.DefaultResolver, Binding{}.Resolver())
+	assert.Nil(t, Binding{}.Dialer().Control)
+	assert.Equal(t, "172.18.0.1:53", withDefaultPort("172.18.0.1"))
+	assert.Equal(t, "[fe80::1]:53", withDefaultPort("fe80::1"))
+	assert.Equal(t, "172.18.0.1:5353", withDefaultPort("172.18.0.1:5353"))
+}
+
+func TestTransportBindsToInterface(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("binding to an interface is only implemented on linux")
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: Binding{Interface: "lo"}.Transport(), Timeout: time.Second}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Skipf("binding to lo is not permitted here: %s", err)
+	}
+	resp.Body.Close()
+
+	client = &http.Client{Transport: Binding{Interface: "trk-missing0"}.Transport(), Timeout: time.Second}
+	_, err = client.Get(srv.URL)
+	assert.Error(t, err)
+}
+
+func TestRotation(t *testing.T) {
+	r := &rotation{servers: []string{"a:53", "b:53", "c:53"}}
+	var mu sync.Mutex
+	var dialed []string
+	down := map[string]bool{"a:53": true}
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		dialed = append(dialed, address)
+		if down[address] {
+			return nil, errors.New("unreachable")
+		}
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+
+	conn, err := r.dial(context.Background(), "udp", dial)
+	if assert.NoError(t, err) {
+		conn.Close()
+	}
+	assert.Equal(t, []string{"a:53", "b:53"}, dialed)
+
+	// the next lookup starts after the failed server, also while dialing concurrently
+	dialed = nil
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if conn, err := r.dial(context.Background(), "udp", dial); err == nil {
+				conn.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, []string{"b:53", "b:53"}, dialed)
+
+	down["b:53"], down["c:53"] = true, true
+	_, err = r.dial(context.Background(), "udp", dial)
+	assert.Error(t, err)
+}
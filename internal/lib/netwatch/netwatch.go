@@ -0,0 +1,117 @@
+// Package netwatch reports changes of the network which make probing for a portal worthwhile:
+// NetworkManager switching the active connection and the system resuming from sleep.
+package netwatch
+
+import (
+	"context"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	nmInterface         = "org.freedesktop.NetworkManager"
+	nmPath              = "/org/freedesktop/NetworkManager"
+	propertiesInterface = "org.freedesktop.DBus.Properties"
+	logindInterface     = "org.freedesktop.login1.Manager"
+	logindPath          = "/org/freedesktop/login1"
+)
+
+// Reasons sent by Watch
+const (
+	NetworkChanged = "network changed"
+	Resumed        = "resumed from sleep"
+)
+
+// Bus is the part of a system bus connection needed for receiving signals, *dbus.Conn implements it
+type Bus interface {
+	AddMatchSignal(options ...dbus.MatchOption) error
+	RemoveMatchSignal(options ...dbus.MatchOption) error
+	Signal(ch chan<- *dbus.Signal)
+	RemoveSignal(ch chan<- *dbus.Signal)
+}
+
+var matches = [][]dbus.MatchOption{
+	{
+		dbus.WithMatchObjectPath(nmPath),
+		dbus.WithMatchInterface(propertiesInterface),
+		dbus.WithMatchMember("PropertiesChanged"),
+		dbus.WithMatchArg(0, nmInterface),
+	},
+	{
+		dbus.WithMatchObjectPath(logindPath),
+		dbus.WithMatchInterface(logindInterface),
+		dbus.WithMatchMember("PrepareForSleep"),
+	},
+}
+
+// Watch sends the reason of every relevant change to the returned channel until ctx is cancelled.
+// Changes arriving while the previous one has not been read yet are dropped, so a burst of
+// signals results in a single probe.
+func Watch(ctx context.Context, bus Bus) (<-chan string, error) {
+	for i, match := range matches {
+		if err := bus.AddMatchSignal(match...); err != nil {
+			for _, added := range matches[:i] {
+				_ = bus.RemoveMatchSignal(added...)
+			}
+			return nil, err
+		}
+	}
+	signals := make(chan *dbus.Signal, 16)
+	bus.Signal(signals)
+
+	reasons := make(chan string, 1)
+	go func() {
+		defer func() {
+			bus.RemoveSignal(signals)
+			for _, match := range matches {
+				_ = bus.RemoveMatchSignal(match...)
+			}
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case signal, ok := <-signals:
+				if !ok {
+					return
+				}
+				reason := reasonOf(signal)
+				if reason == "" {
+					continue
+				}
+				select {
+				case reasons <- reason:
+				default:
+				}
+			}
+		}
+	}()
+	return reasons, nil
+}
+
+// reasonOf returns why the signal should trigger a probe, empty if it should not
+func reasonOf(signal *dbus.Signal) string {
+	switch {
+	case signal.Path == nmPath && signal.Name == propertiesInterface+".PropertiesChanged":
+		if len(signal.Body) < 2 {
+			return ""
+		}
+		if iface, _ := signal.Body[0].(string); iface != nmInterface {
+			return ""
+		}
+		changed, _ := signal.Body[1].(map[string]dbus.Variant)
+		for _, property := range []string{"ActiveConnections", "PrimaryConnection", "State"} {
+			if _, ok := changed[property]; ok {
+				return NetworkChanged
+			}
+		}
+	case signal.Path == logindPath && signal.Name == logindInterface+".PrepareForSleep":
+		// PrepareForSleep is sent with true before suspending and with false after resuming
+		if len(signal.Body) == 1 {
+			if sleeping, ok := signal.Body[0].(bool); ok && !sleeping {
+				return Resumed
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,138 @@
+package netwatch
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBus delivers the signals passed to emit to all registered channels
+type fakeBus struct {
+	mu       sync.Mutex
+	matches  int
+	channels []chan<- *dbus.Signal
+}
+
+func (f *fakeBus) AddMatchSignal(options ...dbus.MatchOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.matches++
+	return nil
+}
+
+func (f *fakeBus) RemoveMatchSignal(options ...dbus.MatchOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.matches--
+	return nil
+}
+
+func (f *fakeBus) Signal(ch chan<- *dbus.Signal) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.channels = append(f.channels, ch)
+}
+
+func (f *fakeBus) RemoveSignal(ch chan<- *dbus.Signal) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, c := range f.channels {
+		if c == ch {
+			f.channels = append(f.channels[:i], f.channels[i+1:]...)
+			return
+		}
+	}
+}
+
+func (f *fakeBus) emit(signal *dbus.Signal) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, ch := range f.channels {
+		ch <- signal
+	}
+}
+
+func propertiesChanged(properties ...string) *dbus.Signal {
+	changed := map[string]dbus.Variant{}
+	for _, property := range properties {
+		changed[property] = dbus.MakeVariant("")
+	}
+	return &dbus.Signal{
+		Path: nmPath,
+		Name: propertiesInterface + ".PropertiesChanged",
+		Body: []interface{}{nmInterface, changed, []string{}},
+	}
+}
+
+func prepareForSleep(sleeping bool) *dbus.Signal {
+	return &dbus.Signal{
+		Path: logindPath,
+		Name: logindInterface + ".PrepareForSleep",
+		Body: []interface{}{sleeping},
+	}
+}
+
+func receive(t *testing.T, reasons <-chan string) string {
+	select {
+	case reason := <-reasons:
+		return reason
+	case <-time.After(time.Second):
+		t.Fatal("no reason received")
+		return ""
+	}
+}
+
+func TestWatch(t *testing.T) {
+	bus := &fakeBus{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reasons, err := Watch(ctx, bus)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 2, bus.matches)
+
+	bus.emit(propertiesChanged("ActiveConnections"))
+	assert.Equal(t, NetworkChanged, receive(t, reasons))
+
+	// neither suspending nor unrelated properties trigger a probe
+	bus.emit(prepareForSleep(true))
+	bus.emit(propertiesChanged("WirelessEnabled"))
+	bus.emit(prepareForSleep(false))
+	assert.Equal(t, Resumed, receive(t, reasons))
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		bus.mu.Lock()
+		defer bus.mu.Unlock()
+		return bus.matches == 0 && len(bus.channels) == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestWatchDropsBursts(t *testing.T) {
+	bus := &fakeBus{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reasons, err := Watch(ctx, bus)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for i := 0; i < 5; i++ {
+		bus.emit(propertiesChanged("PrimaryConnection", "State"))
+	}
+	assert.Equal(t, NetworkChanged, receive(t, reasons))
+	select {
+	case <-reasons:
+		// the burst may have been split by reading the first reason, but never more than twice
+	case <-time.After(50 * time.Millisecond):
+	}
+	select {
+	case reason := <-reasons:
+		t.Fatalf("unexpected reason %q", reason)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
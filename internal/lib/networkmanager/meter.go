@@ -0,0 +1,94 @@
+package networkmanager
+
+import (
+	"sync"
+	"trk/internal/lib/types"
+)
+
+// Meter marks the train's WiFi connection as metered while the internet is weak,
+// so sync clients and updaters back off. The original setting is restored afterwards.
+type Meter struct {
+	NM NetworkManager
+	// SSIDs are the networks of the train, other connections are never touched
+	SSIDs []string
+	// States are the connectivity states during which the connection is metered
+	States []string
+
+	mu sync.Mutex
+	// attempted is set once marking was tried during the current weak period, whether
+	// or not anything was marked, so the connections are not queried on every status
+	attempted bool
+	marked    []WifiConnection
+}
+
+func NewMeter(nm NetworkManager) *Meter {
+	return &Meter{
+		NM:     nm,
+		SSIDs:  []string{"WIFIonICE", "WIFI@DB"},
+		States: []string{types.ConnectivityWeak, types.ConnectivityUnstable, types.ConnectivityNone},
+	}
+}
+
+// Update marks or restores the connection for the current connectivity
+func (m *Meter) Update(connectivity types.Connectivity) error {
+	if contains(m.States, connectivity.CurrentState) {
+		return m.mark()
+	}
+	return m.Restore()
+}
+
+func (m *Meter) mark() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.attempted {
+		return nil
+	}
+	m.attempted = true
+	connections, err := m.NM.ActiveWifiConnections()
+	if err != nil {
+		return err
+	}
+	err = nil
+	for _, connection := range connections {
+		if !contains(m.SSIDs, connection.SSID) || connection.Metered == MeteredYes {
+			continue
+		}
+		if setErr := m.NM.SetMetered(connection, MeteredYes); setErr != nil {
+			if err == nil {
+				err = setErr
+			}
+			continue
+		}
+		m.marked = append(m.marked, connection)
+	}
+	return err
+}
+
+// Restore resets the metered setting of all connections marked by the meter, the first error is returned.
+// Connections which could not be reset are kept and retried on the next call.
+func (m *Meter) Restore() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.attempted = false
+	var err error
+	var failed []WifiConnection
+	for _, connection := range m.marked {
+		if setErr := m.NM.SetMetered(connection, connection.Metered); setErr != nil {
+			if err == nil {
+				err = setErr
+			}
+			failed = append(failed, connection)
+		}
+	}
+	m.marked = failed
+	return err
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package networkmanager
+
+import (
+	"errors"
+	"testing"
+	"trk/internal/lib/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeNM keeps the metered setting of its connections in memory
+type fakeNM struct {
+	connections []WifiConnection
+	queries     int
+	calls       int
+	err         error
+}
+
+func (f *fakeNM) ActiveWifiConnections() ([]WifiConnection, error) {
+	f.queries++
+	return append([]WifiConnection(nil), f.connections...), f.err
+}
+
+func (f *fakeNM) SetMetered(connection WifiConnection, metered Metered) error {
+	f.calls++
+	if f.err != nil {
+		return f.err
+	}
+	for i := range f.connections {
+		if f.connections[i].Settings == connection.Settings {
+			f.connections[i].Metered = metered
+		}
+	}
+	return nil
+}
+
+func connectivity(state string) types.Connectivity {
+	return types.Connectivity{CurrentState: state}
+}
+
+func TestMeterMarksTrainWifi(t *testing.T) {
+	nm := &fakeNM{connections: []WifiConnection{
+		{Id: "WIFIonICE", SSID: "WIFIonICE", Settings: "/org/freedesktop/NetworkManager/Settings/3", Metered: MeteredGuessNo},
+		{Id: "Phone", SSID: "Phone", Settings: "/org/freedesktop/NetworkManager/Settings/4"},
+	}}
+	meter := NewMeter(nm)
+
+	assert.NoError(t, meter.Update(connectivity(types.ConnectivityHigh)))
+	assert.Equal(t, 0, nm.calls)
+
+	assert.NoError(t, meter.Update(connectivity(types.ConnectivityWeak)))
+	assert.Equal(t, MeteredYes, nm.connections[0].Metered)
+	assert.Equal(t, MeteredUnknown, nm.connections[1].Metered)
+
+	assert.NoError(t, meter.Update(connectivity(types.ConnectivityNone)))
+	assert.Equal(t, 1, nm.calls)
+
+	assert.NoError(t, meter.Update(connectivity(types.ConnectivityHigh)))
+	assert.Equal(t, MeteredGuessNo, nm.connections[0].Metered)
+	assert.Equal(t, 2, nm.calls)
+
+	assert.NoError(t, meter.Restore())
+	assert.Equal(t, 2, nm.calls)
+}
+
+func TestMeterKeepsMeteredConnections(t *testing.T) {
+	nm := &fakeNM{connections: []WifiConnection{
+		{Id: "WIFIonICE", SSID: "WIFIonICE", Settings: "/org/freedesktop/NetworkManager/Settings/3", Metered: MeteredYes},
+	}}
+	meter := NewMeter(nm)
+	assert.NoError(t, meter.Update(connectivity(types.ConnectivityWeak)))
+	assert.NoError(t, meter.Update(connectivity(types.ConnectivityWeak)))
+	assert.Equal(t, 1, nm.queries)
+	assert.NoError(t, meter.Restore())
+	assert.Equal(t, 0, nm.calls)
+	assert.Equal(t, MeteredYes, nm.connections[0].Metered)
+}
+
+func TestMeterRetriesInTheNextWeakPeriod(t *testing.T) {
+	nm := &fakeNM{
+		connections: []WifiConnection{{Id: "WIFIonICE", SSID: "WIFIonICE", Settings: "/org/freedesktop/NetworkManager/Settings/3"}},
+		err:         errors.New("access denied"),
+	}
+	meter := NewMeter(nm)
+	assert.Error(t, meter.Update(connectivity(types.ConnectivityWeak)))
+
+	nm.err = nil
+	assert.NoError(t, meter.Update(connectivity(types.ConnectivityWeak)))
+	assert.Equal(t, 1, nm.queries)
+	assert.Equal(t, MeteredUnknown, nm.connections[0].Metered)
+
+	assert.NoError(t, meter.Update(connectivity(types.ConnectivityHigh)))
+	assert.NoError(t, meter.Update(connectivity(types.ConnectivityWeak)))
+	assert.Equal(t, 2, nm.queries)
+	assert.Equal(t, MeteredYes, nm.connections[0].Metered)
+}
+
+func TestMeterRetriesFailedRestore(t *testing.T) {
+	nm := &fakeNM{connections: []WifiConnection{
+		{Id: "WIFIonICE", SSID: "WIFIonICE", Settings: "/org/freedesktop/NetworkManager/Settings/3", Metered: MeteredGuessNo},
+	}}
+	meter := NewMeter(nm)
+	assert.NoError(t, meter.Update(connectivity(types.ConnectivityWeak)))
+
+	nm.err = errors.New("access denied")
+	assert.Error(t, meter.Restore())
+	assert.Equal(t, MeteredYes, nm.connections[0].Metered)
+
+	nm.err = nil
+	assert.NoError(t, meter.Restore())
+	assert.Equal(t, MeteredGuessNo, nm.connections[0].Metered)
+	calls := nm.calls
+	assert.NoError(t, meter.Restore())
+	assert.Equal(t, calls, nm.calls)
+}
@@ -0,0 +1,167 @@
+// Package networkmanager talks to NetworkManager over the system D-Bus.
+package networkmanager
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	busName            = "org.freedesktop.NetworkManager"
+	objectPath         = "/org/freedesktop/NetworkManager"
+	activeInterface    = "org.freedesktop.NetworkManager.Connection.Active"
+	settingsInterface  = "org.freedesktop.NetworkManager.Settings.Connection"
+	deviceInterface    = "org.freedesktop.NetworkManager.Device"
+	ip4ConfigInterface = "org.freedesktop.NetworkManager.IP4Config"
+	wirelessType       = "802-11-wireless"
+)
+
+// Metered is the value of the connection.metered setting
+type Metered int32
+
+const (
+	MeteredUnknown Metered = iota
+	MeteredYes
+	MeteredNo
+	MeteredGuessYes
+	MeteredGuessNo
+)
+
+// WifiConnection is an active WiFi connection
+type WifiConnection struct {
+	// Id is the name of the connection, usually the SSID
+	Id   string
+	SSID string
+	// Settings is the path of the connection's settings object
+	Settings dbus.ObjectPath
+	Devices  []dbus.ObjectPath
+	Metered  Metered
+	// Interface is the network interface of the first device, e.g. wlp3s0
+	Interface string
+	// Nameservers are the DNS servers of the interface, usually handed out by DHCP
+	Nameservers []string
+}
+
+// NetworkManager is the part of NetworkManager's D-Bus API trk uses
+type NetworkManager interface {
+	// ActiveWifiConnections returns the active WiFi connections
+	ActiveWifiConnections() ([]WifiConnection, error)
+	// SetMetered changes the metered setting of the connection until NetworkManager restarts
+	SetMetered(connection WifiConnection, metered Metered) error
+}
+
+// DBus is the NetworkManager on the system bus
+type DBus struct {
+	conn *dbus.Conn
+}
+
+// NewDBus uses the system bus connection conn to talk to NetworkManager
+func NewDBus(conn *dbus.Conn) *DBus {
+	return &DBus{conn: conn}
+}
+
+func (n *DBus) ActiveWifiConnections() ([]WifiConnection, error) {
+	var activePaths []dbus.ObjectPath
+	if err := n.conn.Object(busName, objectPath).StoreProperty(busName+".ActiveConnections", &activePaths); err != nil {
+		return nil, fmt.Errorf("reading active connections: %w", err)
+	}
+	var connections []WifiConnection
+	for _, path := range activePaths {
+		active := n.conn.Object(busName, path)
+		var connectionType string
+		if err := active.StoreProperty(activeInterface+".Type", &connectionType); err != nil {
+			// the connection may have been deactivated in the meantime
+			continue
+		}
+		if connectionType != wirelessType {
+			continue
+		}
+		connection := WifiConnection{}
+		if err := active.StoreProperty(activeInterface+".Id", &connection.Id); err != nil {
+			return nil, err
+		}
+		if err := active.StoreProperty(activeInterface+".Connection", &connection.Settings); err != nil {
+			return nil, err
+		}
+		if err := active.StoreProperty(activeInterface+".Devices", &connection.Devices); err != nil {
+			return nil, err
+		}
+		settings, err := n.getSettings(connection.Settings)
+		if err != nil {
+			return nil, err
+		}
+		if ssid, ok := settings[wirelessType]["ssid"].Value().([]byte); ok {
+			connection.SSID = string(ssid)
+		}
+		if metered, ok := settings["connection"]["metered"].Value().(int32); ok {
+			connection.Metered = Metered(metered)
+		}
+		if len(connection.Devices) > 0 {
+			if err = n.readDevice(connection.Devices[0], &connection); err != nil {
+				return nil, err
+			}
+		}
+		connections = append(connections, connection)
+	}
+	return connections, nil
+}
+
+func (n *DBus) SetMetered(connection WifiConnection, metered Metered) error {
+	settings, err := n.getSettings(connection.Settings)
+	if err != nil {
+		return err
+	}
+	// NetworkManager rejects the deprecated address fields it returns itself
+	for _, ip := range []string{"ipv4", "ipv6"} {
+		delete(settings[ip], "addresses")
+		delete(settings[ip], "routes")
+	}
+	settings["connection"]["metered"] = dbus.MakeVariant(int32(metered))
+	if err = n.conn.Object(busName, connection.Settings).Call(settingsInterface+".UpdateUnsaved", 0, settings).Err; err != nil {
+		return fmt.Errorf("updating %s: %w", connection.Id, err)
+	}
+	for _, device := range connection.Devices {
+		// an empty connection reapplies the updated settings
+		err = n.conn.Object(busName, device).Call(deviceInterface+".Reapply", 0,
+			map[string]map[string]dbus.Variant{}, uint64(0), uint32(0)).Err
+		if err != nil {
+			return fmt.Errorf("reapplying %s: %w", connection.Id, err)
+		}
+	}
+	return nil
+}
+
+// readDevice sets the interface and the DNS servers of the device at path
+func (n *DBus) readDevice(path dbus.ObjectPath, connection *WifiConnection) error {
+	device := n.conn.Object(busName, path)
+	if err := device.StoreProperty(deviceInterface+".IpInterface", &connection.Interface); err != nil {
+		return fmt.Errorf("reading interface of %s: %w", connection.Id, err)
+	}
+	var ip4Config dbus.ObjectPath
+	if err := device.StoreProperty(deviceInterface+".Ip4Config", &ip4Config); err != nil || ip4Config == "/" {
+		// no IPv4 configuration yet, e.g. while DHCP is running
+		return nil
+	}
+	var nameservers []map[string]dbus.Variant
+	if err := n.conn.Object(busName, ip4Config).StoreProperty(ip4ConfigInterface+".NameserverData", &nameservers); err != nil {
+		return fmt.Errorf("reading DNS servers of %s: %w", connection.Id, err)
+	}
+	for _, nameserver := range nameservers {
+		if address, ok := nameserver["address"].Value().(string); ok {
+			connection.Nameservers = append(connection.Nameservers, address)
+		}
+	}
+	return nil
+}
+
+func (n *DBus) getSettings(path dbus.ObjectPath) (map[string]map[string]dbus.Variant, error) {
+	var settings map[string]map[string]dbus.Variant
+	if err := n.conn.Object(busName, path).Call(settingsInterface+".GetSettings", 0).Store(&settings); err != nil {
+		return nil, fmt.Errorf("reading settings of %s: %w", path, err)
+	}
+	if settings["connection"] == nil {
+		settings["connection"] = map[string]dbus.Variant{}
+	}
+	return settings, nil
+}
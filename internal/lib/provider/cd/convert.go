@@ -0,0 +1,96 @@
+package cd
+
+import (
+	"fmt"
+	"time"
+	"trk/internal/lib/provider/portal"
+	"trk/internal/lib/types"
+)
+
+// operator is the vagonweb.cz country code of České dráhy
+const operator = "CD"
+
+var trainTypes = map[string]string{
+	"RJ": "Railjet",
+	"EC": "Eurocity",
+	"IC": "Intercity",
+	"SC": "SuperCity Pendolino",
+	"EN": "EuroNight",
+	"Ex": "Express",
+	"R":  "Rychlík",
+}
+
+// ConvertTrain maps the train of the timetable to types.Train
+func ConvertTrain(timetable Timetable) types.Train {
+	name := fmt.Sprintf("%s %s", timetable.TrainType, timetable.TrainNumber)
+	seriesDisplay := trainTypes[timetable.TrainType]
+	if timetable.TrainName != "" {
+		seriesDisplay = fmt.Sprintf("%s %s", seriesDisplay, timetable.TrainName)
+	}
+	return types.Train{
+		Id:            timetable.TrainType + timetable.TrainNumber,
+		DisplayName:   name,
+		LookupString:  name,
+		Type:          timetable.TrainType,
+		Line:          timetable.TrainNumber,
+		SeriesDisplay: seriesDisplay,
+		Operator:      operator,
+	}
+}
+
+// ConvertStatus maps the realtime data and the timetable to a types.Status
+func ConvertStatus(realtime Realtime, timetable *Timetable, now time.Time) types.Status {
+	result := types.Status{
+		Speed:     realtime.Speed / 3.6, // the portal reports km/h
+		Timestamp: now,
+		Delay:     time.Duration(realtime.Delay) * time.Minute,
+		Location: types.Location{
+			Latitude:  realtime.GpsLat,
+			Longitude: realtime.GpsLng,
+		},
+	}
+	if timetable == nil {
+		return result
+	}
+	result.Train = ConvertTrain(*timetable)
+	if next := types.Trip(ConvertStops(timetable, realtime.Delay)).GetNextStop(); next != nil {
+		result.NextStop = *next
+	}
+	return result
+}
+
+// ConvertStops maps the stations to types.Stop. The portal only knows the scheduled times,
+// the current delay in minutes is added to all stations which have not been passed.
+func ConvertStops(timetable *Timetable, delay int) []types.Stop {
+	if timetable == nil {
+		return nil
+	}
+	stops := make([]types.Stop, 0, len(timetable.Stations))
+	for _, station := range timetable.Stations {
+		stop := types.Stop{
+			Id:   station.Code,
+			Name: station.Name,
+			Location: types.Location{
+				Latitude:  station.Lat,
+				Longitude: station.Lng,
+			},
+			ScheduledArrivalTime:   portal.ParseTime(station.Arrival),
+			ScheduledDepartureTime: portal.ParseTime(station.Departure),
+			Track:                  station.Track,
+			Passed:                 station.Passed,
+			Cancelled:              station.Cancelled,
+		}
+		stop.ArrivalTime = stop.ScheduledArrivalTime
+		stop.DepartureTime = stop.ScheduledDepartureTime
+		if !station.Passed && delay > 0 {
+			if !stop.ArrivalTime.IsZero() {
+				stop.ArrivalTime = stop.ArrivalTime.Add(time.Duration(delay) * time.Minute)
+			}
+			if !stop.DepartureTime.IsZero() {
+				stop.DepartureTime = stop.DepartureTime.Add(time.Duration(delay) * time.Minute)
+			}
+		}
+		stops = append(stops, stop)
+	}
+	return stops
+}
@@ -0,0 +1,62 @@
+// Package cd reads the onboard portal of Czech ČD trains (Railjet, ComfortJet) at cdwifi.cz.
+//
+// The portal has two endpoints, /portal/api/vehicle/realtime with the position, the speed
+// and the delay and /portal/api/timetable with the train and its stations. The fixtures in
+// testdata follow the structure of these responses.
+package cd
+
+import (
+	"context"
+	"sync"
+	"trk/internal/lib/provider/portal"
+	"trk/internal/lib/types"
+)
+
+type Provider struct {
+	*portal.Client
+
+	mu        sync.Mutex
+	realtime  Realtime
+	timetable *Timetable
+}
+
+func NewCDProvider() *Provider {
+	client := portal.NewClient("http://cdwifi.cz")
+	// cdwifi.cz only resolves to the portal inside the train
+	client.ValidAddress = portal.Onboard
+	client.SSIDs = []string{"CDWiFi"}
+	return &Provider{Client: client}
+}
+
+// Probe checks whether the portal returns a position. Outside the train cdwifi.cz does not answer the api.
+func (p *Provider) Probe() (bool, error) {
+	return p.Client.Probe("/portal/api/vehicle/realtime", &Realtime{})
+}
+
+func (p *Provider) Run(ctx context.Context, statusChan chan types.Status) error {
+	return p.Poll(ctx, statusChan, p.fetch)
+}
+
+// fetch fetches the position and the timetable and converts them into a status
+func (p *Provider) fetch(ctx context.Context) (types.Status, error) {
+	realtime := Realtime{}
+	now, err := p.Get(ctx, "/portal/api/vehicle/realtime", &realtime)
+	if err != nil {
+		return types.Status{}, err
+	}
+	timetable := &Timetable{}
+	if _, err = p.Get(ctx, "/portal/api/timetable", timetable); err != nil {
+		return types.Status{}, err
+	}
+	p.mu.Lock()
+	p.realtime = realtime
+	p.timetable = timetable
+	p.mu.Unlock()
+	return ConvertStatus(realtime, timetable, now), nil
+}
+
+func (p *Provider) GetStops() []types.Stop {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return ConvertStops(p.timetable, p.realtime.Delay)
+}
@@ -0,0 +1,58 @@
+package cd
+
+import (
+	"context"
+	"testing"
+	"time"
+	"trk/internal/lib/provider/portal/portaltest"
+	"trk/internal/lib/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider(t *testing.T) {
+	srv := portaltest.NewServer(map[string]string{
+		"/portal/api/vehicle/realtime": "testdata/realtime.json",
+		"/portal/api/timetable":        "testdata/timetable.json",
+	})
+	defer srv.Close()
+	p := NewCDProvider()
+	p.BaseURL = srv.URL
+	p.Resolver = portaltest.Resolver{}
+	p.PollInterval = time.Millisecond
+
+	ok, err := p.Probe()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	statusChan := make(chan types.Status)
+	done := make(chan error)
+	go func() {
+		done <- p.Run(ctx, statusChan)
+	}()
+
+	status := <-statusChan
+	assert.Equal(t, "RJ73", status.Train.Id)
+	assert.Equal(t, "RJ 73", status.Train.DisplayName)
+	assert.Equal(t, "Railjet Smetana", status.Train.SeriesDisplay)
+	assert.Equal(t, "CD", status.Train.Operator)
+	assert.InDelta(t, 158.0/3.6, status.Speed, 0.001)
+	assert.Equal(t, 4*time.Minute, status.Delay)
+	assert.InDelta(t, 49.946537, status.Location.Latitude, 0.000001)
+	assert.Equal(t, "Česká Třebová", status.NextStop.Name)
+	assert.Equal(t, 4*time.Minute, status.NextStop.ArrivalDelay())
+
+	stops := p.GetStops()
+	if assert.Len(t, stops, 6) {
+		assert.True(t, stops[1].Passed)
+		assert.Equal(t, time.Duration(0), stops[1].DepartureDelay())
+		assert.Equal(t, "2023-03-04T11:22:00+01:00", stops[3].DepartureTime.Format(time.RFC3339))
+		assert.True(t, stops[4].Cancelled)
+		assert.True(t, stops[5].DepartureTime.IsZero())
+	}
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
@@ -0,0 +1,35 @@
+package cd
+
+// http://cdwifi.cz/portal/api/vehicle/realtime
+type Realtime struct {
+	GpsLat float64 `json:"gpsLat"`
+	GpsLng float64 `json:"gpsLng"`
+	// Speed is in km/h
+	Speed float64 `json:"speed"`
+	// Delay is the current delay in minutes
+	Delay       int     `json:"delay"`
+	Altitude    float64 `json:"altitude"`
+	Temperature float64 `json:"temperature"`
+}
+
+// http://cdwifi.cz/portal/api/timetable
+type Timetable struct {
+	TrainType   string    `json:"trainType"`
+	TrainNumber string    `json:"trainNumber"`
+	TrainName   string    `json:"trainName"`
+	Stations    []Station `json:"stations"`
+}
+
+type Station struct {
+	// Code is the UIC station number
+	Code string `json:"code"`
+	Name string `json:"name"`
+	// Arrival and Departure are the scheduled times in RFC 3339 format, empty at the first and the last station
+	Arrival   string  `json:"arrival"`
+	Departure string  `json:"departure"`
+	Track     string  `json:"track"`
+	Lat       float64 `json:"lat"`
+	Lng       float64 `json:"lng"`
+	Passed    bool    `json:"passed"`
+	Cancelled bool    `json:"cancelled"`
+}
@@ -0,0 +1,204 @@
+package geoclue
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	busName           = "org.freedesktop.GeoClue2"
+	managerPath       = "/org/freedesktop/GeoClue2/Manager"
+	managerInterface  = "org.freedesktop.GeoClue2.Manager"
+	clientInterface   = "org.freedesktop.GeoClue2.Client"
+	locationInterface = "org.freedesktop.GeoClue2.Location"
+)
+
+// AccuracyLevel is the accuracy requested from GeoClue
+type AccuracyLevel uint32
+
+const (
+	AccuracyNone         AccuracyLevel = 0
+	AccuracyCountry      AccuracyLevel = 1
+	AccuracyCity         AccuracyLevel = 4
+	AccuracyNeighborhood AccuracyLevel = 5
+	AccuracyStreet       AccuracyLevel = 6
+	AccuracyExact        AccuracyLevel = 8
+)
+
+// Location is a position reported by GeoClue
+type Location struct {
+	Latitude  float64
+	Longitude float64
+	// Accuracy is the radius of the position in meters
+	Accuracy float64
+	// Speed is in m/s, negative if unknown
+	Speed     float64
+	Timestamp time.Time
+}
+
+// GeoClue is the part of GeoClue2's D-Bus API trk uses
+type GeoClue interface {
+	// Start creates a client for desktopId and starts locating. Every new location is sent
+	// to the returned channel, which is closed once the client has been stopped.
+	Start(desktopId string, accuracy AccuracyLevel) (<-chan Location, error)
+	// Stop stops and removes the client created by Start
+	Stop() error
+}
+
+// DBus is GeoClue2 on the system bus
+type DBus struct {
+	conn *dbus.Conn
+
+	mu      sync.Mutex
+	client  dbus.ObjectPath
+	signals chan *dbus.Signal
+	done    chan struct{}
+}
+
+// NewDBus uses the system bus connection conn to talk to GeoClue2
+func NewDBus(conn *dbus.Conn) *DBus {
+	return &DBus{conn: conn}
+}
+
+func (d *DBus) Start(desktopId string, accuracy AccuracyLevel) (<-chan Location, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.client != "" {
+		return nil, errors.New("geoclue client already started")
+	}
+
+	var path dbus.ObjectPath
+	if err := d.conn.Object(busName, managerPath).Call(managerInterface+".CreateClient", 0).Store(&path); err != nil {
+		return nil, fmt.Errorf("creating geoclue client: %w", err)
+	}
+	client := d.conn.Object(busName, path)
+	if err := client.SetProperty(clientInterface+".DesktopId", dbus.MakeVariant(desktopId)); err != nil {
+		d.deleteClient(path)
+		return nil, err
+	}
+	if err := client.SetProperty(clientInterface+".RequestedAccuracyLevel", dbus.MakeVariant(uint32(accuracy))); err != nil {
+		d.deleteClient(path)
+		return nil, err
+	}
+
+	match := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(path),
+		dbus.WithMatchInterface(clientInterface),
+		dbus.WithMatchMember("LocationUpdated"),
+	}
+	if err := d.conn.AddMatchSignal(match...); err != nil {
+		d.deleteClient(path)
+		return nil, err
+	}
+	signals := make(chan *dbus.Signal, 10)
+	d.conn.Signal(signals)
+	if err := client.Call(clientInterface+".Start", 0).Err; err != nil {
+		d.conn.RemoveSignal(signals)
+		_ = d.conn.RemoveMatchSignal(match...)
+		d.deleteClient(path)
+		return nil, fmt.Errorf("starting geoclue client: %w", err)
+	}
+	d.client = path
+	d.signals = signals
+	d.done = make(chan struct{})
+
+	locations := make(chan Location, 1)
+	go d.forward(path, signals, d.done, locations)
+	return locations, nil
+}
+
+// forward reads the location of every LocationUpdated signal of client until done is closed
+func (d *DBus) forward(client dbus.ObjectPath, signals <-chan *dbus.Signal, done <-chan struct{}, locations chan Location) {
+	defer close(locations)
+	for {
+		var signal *dbus.Signal
+		select {
+		case s, ok := <-signals:
+			if !ok {
+				// the bus connection has been closed
+				return
+			}
+			signal = s
+		case <-done:
+			return
+		}
+		if signal.Path != client || signal.Name != clientInterface+".LocationUpdated" || len(signal.Body) < 2 {
+			continue
+		}
+		path, ok := signal.Body[1].(dbus.ObjectPath)
+		if !ok {
+			continue
+		}
+		location, err := d.location(path)
+		if err != nil {
+			continue
+		}
+		select {
+		case locations <- location:
+		default:
+			// the previous location has not been read yet, it is outdated now
+			select {
+			case <-locations:
+			default:
+			}
+			locations <- location
+		}
+	}
+}
+
+// location reads the properties of the location object at path
+func (d *DBus) location(path dbus.ObjectPath) (Location, error) {
+	var properties map[string]dbus.Variant
+	err := d.conn.Object(busName, path).Call("org.freedesktop.DBus.Properties.GetAll", 0, locationInterface).Store(&properties)
+	if err != nil {
+		return Location{}, err
+	}
+	location := Location{Speed: -1}
+	for name, target := range map[string]*float64{
+		"Latitude":  &location.Latitude,
+		"Longitude": &location.Longitude,
+		"Accuracy":  &location.Accuracy,
+		"Speed":     &location.Speed,
+	} {
+		if v, ok := properties[name].Value().(float64); ok {
+			*target = v
+		}
+	}
+	var timestamp struct {
+		Seconds      uint64
+		Microseconds uint64
+	}
+	if v, ok := properties["Timestamp"]; ok && v.Store(&timestamp) == nil {
+		location.Timestamp = time.Unix(int64(timestamp.Seconds), int64(timestamp.Microseconds)*int64(time.Microsecond))
+	}
+	return location, nil
+}
+
+func (d *DBus) Stop() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.client == "" {
+		return nil
+	}
+	err := d.conn.Object(busName, d.client).Call(clientInterface+".Stop", 0).Err
+	_ = d.conn.RemoveMatchSignal(
+		dbus.WithMatchObjectPath(d.client),
+		dbus.WithMatchInterface(clientInterface),
+		dbus.WithMatchMember("LocationUpdated"),
+	)
+	d.conn.RemoveSignal(d.signals)
+	close(d.done)
+	d.deleteClient(d.client)
+	d.client = ""
+	d.signals = nil
+	d.done = nil
+	return err
+}
+
+func (d *DBus) deleteClient(path dbus.ObjectPath) {
+	_ = d.conn.Object(busName, managerPath).Call(managerInterface+".DeleteClient", 0, path).Err
+}
@@ -0,0 +1,143 @@
+// Package geoclue follows the position of the desktop's GeoClue2 service, the last resort
+// when neither a portal nor gpsd is available.
+//
+// GeoClue is asked for an exact position. Only positions with a known speed are taken
+// as coming from a satellite receiver, the ones guessed from the WiFi at home have none.
+package geoclue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"trk/internal/lib/types"
+)
+
+// ErrGeoClueGone is returned by Run if GeoClue stopped the client
+var ErrGeoClueGone = errors.New("geoclue is gone")
+
+// train is sent with every status, GeoClue knows nothing about the train but the GPX recording
+// needs an id to name the track
+var train = types.Train{Id: "geoclue"}
+
+type Provider struct {
+	// GeoClue is nil without a system bus, the provider is never available then
+	GeoClue   GeoClue
+	DesktopId string
+	Accuracy  AccuracyLevel
+	// ProbeTimeout is how long Probe waits for a position
+	ProbeTimeout time.Duration
+	// Interval is the minimum time between two status updates
+	Interval time.Duration
+}
+
+func NewGeoClueProvider() *Provider {
+	return &Provider{
+		DesktopId:    "trk",
+		Accuracy:     AccuracyExact,
+		ProbeTimeout: 10 * time.Second,
+		Interval:     5 * time.Second,
+	}
+}
+
+// Probe succeeds if GeoClue reports a position with a known speed within ProbeTimeout
+func (p *Provider) Probe() (bool, error) {
+	if p.GeoClue == nil {
+		return false, nil
+	}
+	locations, err := p.GeoClue.Start(p.DesktopId, p.Accuracy)
+	if err != nil {
+		log.Printf("geoclue not available: %s\n", err.Error())
+		return false, nil
+	}
+	defer p.stop()
+	timeout := time.After(p.ProbeTimeout)
+	for {
+		select {
+		case location, ok := <-locations:
+			if !ok {
+				return false, nil
+			}
+			if location.Speed >= 0 {
+				return true, nil
+			}
+		case <-timeout:
+			return false, nil
+		}
+	}
+}
+
+func (p *Provider) Run(ctx context.Context, statusChan chan types.Status) error {
+	locations, err := p.GeoClue.Start(p.DesktopId, p.Accuracy)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrGeoClueGone, err.Error())
+	}
+	defer p.stop()
+
+	var sent time.Time
+	for {
+		var location Location
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case l, ok := <-locations:
+			if !ok {
+				return ErrGeoClueGone
+			}
+			location = l
+		}
+		if time.Since(sent) < p.Interval {
+			continue
+		}
+		sent = time.Now()
+
+		select {
+		case statusChan <- ConvertStatus(location):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (p *Provider) stop() {
+	if err := p.GeoClue.Stop(); err != nil {
+		log.Printf("error stopping geoclue: %s\n", err.Error())
+	}
+}
+
+// ConvertStatus maps a location of GeoClue to a types.Status
+func ConvertStatus(location Location) types.Status {
+	status := types.Status{
+		Train:     train,
+		Timestamp: location.Timestamp,
+		Location: types.Location{
+			Latitude:  location.Latitude,
+			Longitude: location.Longitude,
+		},
+	}
+	if location.Speed > 0 {
+		status.Speed = location.Speed // GeoClue reports m/s
+	}
+	if status.Timestamp.IsZero() {
+		status.Timestamp = time.Now()
+	}
+	return status
+}
+
+// Fallback marks the provider as one to be replaced by a portal as soon as there is one
+func (p *Provider) Fallback() {}
+
+// GetSSIDs returns nil, GeoClue works in any network
+func (p *Provider) GetSSIDs() []string {
+	return nil
+}
+
+// GetStops returns nil, GeoClue knows nothing about the trip
+func (p *Provider) GetStops() []types.Stop {
+	return nil
+}
+
+func (p *Provider) GetTrainInfo(s string) string {
+	return ""
+}
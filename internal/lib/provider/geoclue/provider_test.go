@@ -0,0 +1,115 @@
+package geoclue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"trk/internal/lib/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeGeoClue sends the locations written to its channel to the started client
+type fakeGeoClue struct {
+	locations chan Location
+	err       error
+	desktopId string
+	accuracy  AccuracyLevel
+	started   int
+	stopped   int
+}
+
+func newFakeGeoClue() *fakeGeoClue {
+	return &fakeGeoClue{locations: make(chan Location)}
+}
+
+func (f *fakeGeoClue) Start(desktopId string, accuracy AccuracyLevel) (<-chan Location, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.desktopId = desktopId
+	f.accuracy = accuracy
+	f.started++
+	return f.locations, nil
+}
+
+func (f *fakeGeoClue) Stop() error {
+	f.stopped++
+	return nil
+}
+
+func TestProbe(t *testing.T) {
+	fake := newFakeGeoClue()
+	p := NewGeoClueProvider()
+	p.GeoClue = fake
+	p.ProbeTimeout = time.Second
+
+	go func() {
+		// a position guessed from the WiFi, then one of a satellite receiver
+		fake.locations <- Location{Latitude: 52.52, Longitude: 13.37, Accuracy: 60, Speed: -1}
+		fake.locations <- Location{Latitude: 52.52, Longitude: 13.37, Accuracy: 5, Speed: 0}
+	}()
+	ok, err := p.Probe()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "trk", fake.desktopId)
+	assert.Equal(t, AccuracyExact, fake.accuracy)
+	assert.Equal(t, 1, fake.stopped)
+}
+
+func TestProbeWithoutSpeed(t *testing.T) {
+	fake := newFakeGeoClue()
+	p := NewGeoClueProvider()
+	p.GeoClue = fake
+	p.ProbeTimeout = 50 * time.Millisecond
+
+	go func() {
+		fake.locations <- Location{Latitude: 52.52, Longitude: 13.37, Accuracy: 60, Speed: -1}
+	}()
+	ok, err := p.Probe()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 1, fake.stopped)
+}
+
+func TestProbeWithoutGeoClue(t *testing.T) {
+	p := NewGeoClueProvider()
+	ok, err := p.Probe()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	fake := newFakeGeoClue()
+	fake.err = errors.New("org.freedesktop.DBus.Error.ServiceUnknown")
+	p.GeoClue = fake
+	ok, err = p.Probe()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestRun(t *testing.T) {
+	fake := newFakeGeoClue()
+	p := NewGeoClueProvider()
+	p.GeoClue = fake
+	p.Interval = 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	statusChan := make(chan types.Status)
+	done := make(chan error)
+	go func() {
+		done <- p.Run(ctx, statusChan)
+	}()
+
+	timestamp := time.Date(2023, 3, 4, 10, 0, 0, 0, time.UTC)
+	fake.locations <- Location{Latitude: 51.43, Longitude: 6.77, Accuracy: 4, Speed: 44.4, Timestamp: timestamp}
+	status := <-statusChan
+	assert.Equal(t, "geoclue", status.Train.Id)
+	assert.Equal(t, 44.4, status.Speed)
+	assert.Equal(t, types.Location{Latitude: 51.43, Longitude: 6.77}, status.Location)
+	assert.Equal(t, timestamp, status.Timestamp)
+
+	close(fake.locations)
+	assert.ErrorIs(t, <-done, ErrGeoClueGone)
+	assert.Equal(t, 1, fake.stopped)
+}
@@ -0,0 +1,33 @@
+package gpsd
+
+import (
+	"time"
+	"trk/internal/lib/types"
+)
+
+// train is sent with every status, gpsd knows nothing about the train but the GPX recording
+// needs an id to name the track
+var train = types.Train{Id: "gpsd"}
+
+// ConvertStatus maps a TPV report to a types.Status. Without a fix the last known position
+// is kept and the status is marked as GPSLost.
+func ConvertStatus(tpv TPV, last types.Location, now time.Time) types.Status {
+	status := types.Status{
+		Train:     train,
+		Timestamp: now,
+		Location:  last,
+		GPSLost:   !tpv.HasFix(),
+	}
+	if !tpv.HasFix() {
+		return status
+	}
+	status.Speed = tpv.Speed // gpsd reports m/s
+	status.Location = types.Location{
+		Latitude:  tpv.Lat,
+		Longitude: tpv.Lon,
+	}
+	if t, err := time.Parse(time.RFC3339, tpv.Time); err == nil {
+		status.Timestamp = t
+	}
+	return status
+}
@@ -0,0 +1,123 @@
+// Package fakegpsd is a minimal gpsd for testing the gpsd provider without a GPS receiver.
+package fakegpsd
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	"trk/internal/lib/provider/gpsd"
+)
+
+// Server speaks the JSON protocol of gpsd on a random local port. After a client enabled
+// watching, it sends the reports one after the other every Interval, starting over at the end.
+type Server struct {
+	Interval time.Duration
+
+	listener net.Listener
+	reports  []gpsd.TPV
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+func NewServer(reports []gpsd.TPV) (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Interval: 10 * time.Millisecond,
+		listener: listener,
+		reports:  reports,
+		conns:    map[net.Conn]struct{}{},
+	}
+	s.wg.Add(1)
+	go s.accept()
+	return s, nil
+}
+
+// Addr is the address the server listens on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Provider returns a gpsd.Provider talking to the server
+func (s *Server) Provider() *gpsd.Provider {
+	return gpsd.NewGPSDProvider(s.Addr())
+}
+
+// Close stops the server and drops all clients, like a gpsd being stopped
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.serve(conn)
+	}
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	encoder := json.NewEncoder(conn)
+	if err := encoder.Encode(gpsd.Version{Class: "VERSION", Release: "3.22", ProtoMajor: 3, ProtoMinor: 14}); err != nil {
+		return
+	}
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		command := strings.TrimSuffix(strings.TrimSpace(scanner.Text()), ";")
+		if !strings.HasPrefix(command, "?WATCH=") {
+			continue
+		}
+		watch := gpsd.Watch{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(command, "?WATCH=")), &watch); err != nil || !watch.Enable {
+			continue
+		}
+		if err := encoder.Encode(gpsd.Report{Class: "DEVICES"}); err != nil {
+			return
+		}
+		s.stream(encoder)
+		return
+	}
+}
+
+func (s *Server) stream(encoder *json.Encoder) {
+	if len(s.reports) == 0 {
+		return
+	}
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for i := 0; ; i = (i + 1) % len(s.reports) {
+		report := s.reports[i]
+		report.Class = "TPV"
+		if err := encoder.Encode(report); err != nil {
+			return
+		}
+		<-ticker.C
+	}
+}
@@ -0,0 +1,89 @@
+package fakegpsd
+
+import (
+	"context"
+	"testing"
+	"time"
+	"trk/internal/lib/provider/gpsd"
+	"trk/internal/lib/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProviderAgainstFakeGPSD(t *testing.T) {
+	srv, err := NewServer([]gpsd.TPV{
+		{Mode: gpsd.ModeNoFix},
+		{Mode: gpsd.Mode3D, Time: "2023-03-04T10:00:00.000Z", Lat: 48.2, Lon: 16.37, Speed: 41.7},
+		{Mode: gpsd.ModeNoFix},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer srv.Close()
+
+	p := srv.Provider()
+	p.Interval = 0
+	ok, err := p.Probe()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	statusChan := make(chan types.Status)
+	done := make(chan error)
+	go func() {
+		done <- p.Run(ctx, statusChan)
+	}()
+
+	status := <-statusChan
+	assert.True(t, status.GPSLost)
+	assert.Equal(t, types.Location{}, status.Location)
+
+	status = <-statusChan
+	assert.False(t, status.GPSLost)
+	assert.Equal(t, "gpsd", status.Train.Id)
+	assert.Equal(t, 41.7, status.Speed)
+	assert.Equal(t, types.Location{Latitude: 48.2, Longitude: 16.37}, status.Location)
+	assert.True(t, status.Timestamp.Equal(time.Date(2023, 3, 4, 10, 0, 0, 0, time.UTC)))
+
+	// the last position is kept while the fix is lost
+	status = <-statusChan
+	assert.True(t, status.GPSLost)
+	assert.Equal(t, types.Location{Latitude: 48.2, Longitude: 16.37}, status.Location)
+
+	srv.Close()
+	for {
+		select {
+		case <-statusChan:
+		case err := <-done:
+			assert.ErrorIs(t, err, gpsd.ErrGPSGone)
+			return
+		}
+	}
+}
+
+func TestProbeWithoutFix(t *testing.T) {
+	srv, err := NewServer([]gpsd.TPV{{Mode: gpsd.ModeNoFix}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer srv.Close()
+
+	p := srv.Provider()
+	p.ProbeTimeout = 50 * time.Millisecond
+	ok, err := p.Probe()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestProbeWithoutGPSD(t *testing.T) {
+	srv, err := NewServer(nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	p := srv.Provider()
+	srv.Close()
+	ok, err := p.Probe()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
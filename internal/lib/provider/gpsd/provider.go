@@ -0,0 +1,176 @@
+// Package gpsd follows the position of a GPS receiver through a local gpsd, for trains without a portal.
+//
+// It speaks the JSON protocol of gpsd: after connecting gpsd sends a VERSION report,
+// ?WATCH={"enable":true,"json":true}; starts the stream of reports, of which only
+// the TPV (time-position-velocity) reports are used. The train and the stops stay unknown.
+package gpsd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+	"trk/internal/lib/types"
+)
+
+// DefaultAddress is where gpsd listens by default
+const DefaultAddress = "localhost:2947"
+
+// ErrGPSGone is returned by Run if gpsd closed the connection or stopped reporting
+var ErrGPSGone = errors.New("gpsd is gone")
+
+type Provider struct {
+	Address     string
+	DialTimeout time.Duration
+	// ProbeTimeout is how long Probe waits for a position
+	ProbeTimeout time.Duration
+	// Interval is the minimum time between two status updates, gpsd reports about once a second
+	Interval time.Duration
+	// Timeout ends Run if gpsd does not report anything for this long
+	Timeout time.Duration
+}
+
+func NewGPSDProvider(address string) *Provider {
+	return &Provider{
+		Address:      address,
+		DialTimeout:  2 * time.Second,
+		ProbeTimeout: 5 * time.Second,
+		Interval:     5 * time.Second,
+		Timeout:      30 * time.Second,
+	}
+}
+
+// session is a connection to gpsd which has been told to stream its reports
+type session struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+}
+
+func (p *Provider) dial(ctx context.Context) (*session, error) {
+	dialer := net.Dialer{Timeout: p.DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", p.Address)
+	if err != nil {
+		return nil, err
+	}
+	watch, err := json.Marshal(Watch{Enable: true, JSON: true})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if _, err = fmt.Fprintf(conn, "?WATCH=%s;\n", watch); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &session{conn: conn, scanner: bufio.NewScanner(conn)}, nil
+}
+
+// next returns the next TPV report, other reports are skipped
+func (s *session) next(timeout time.Duration) (TPV, error) {
+	for {
+		if err := s.conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			return TPV{}, err
+		}
+		if !s.scanner.Scan() {
+			if err := s.scanner.Err(); err != nil {
+				return TPV{}, err
+			}
+			return TPV{}, errors.New("connection closed")
+		}
+		report := Report{}
+		if err := json.Unmarshal(s.scanner.Bytes(), &report); err != nil || report.Class != "TPV" {
+			continue
+		}
+		tpv := TPV{}
+		if err := json.Unmarshal(s.scanner.Bytes(), &tpv); err != nil {
+			return TPV{}, err
+		}
+		return tpv, nil
+	}
+}
+
+// Probe succeeds if gpsd reports a position within ProbeTimeout
+func (p *Provider) Probe() (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.ProbeTimeout)
+	defer cancel()
+	s, err := p.dial(ctx)
+	if err != nil {
+		log.Printf("gpsd not available: %s\n", err.Error())
+		return false, nil
+	}
+	defer s.conn.Close()
+	deadline, _ := ctx.Deadline()
+	for {
+		tpv, err := s.next(time.Until(deadline))
+		if err != nil {
+			log.Printf("gpsd has no position: %s\n", err.Error())
+			return false, nil
+		}
+		if tpv.HasFix() {
+			return true, nil
+		}
+	}
+}
+
+func (p *Provider) Run(ctx context.Context, statusChan chan types.Status) error {
+	s, err := p.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrGPSGone, err.Error())
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		s.conn.Close()
+	}()
+
+	var last types.Location
+	var sent time.Time
+	lost := false
+	for {
+		tpv, err := s.next(p.Timeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("%w: %s", ErrGPSGone, err.Error())
+		}
+		status := ConvertStatus(tpv, last, time.Now())
+		last = status.Location
+		// losing or regaining the fix is sent right away, positions at most every Interval
+		if status.GPSLost == lost && time.Since(sent) < p.Interval {
+			continue
+		}
+		lost = status.GPSLost
+		sent = time.Now()
+
+		select {
+		case statusChan <- status:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Fallback marks the provider as one to be replaced by a portal as soon as there is one
+func (p *Provider) Fallback() {}
+
+// GetSSIDs returns nil, the GPS receiver works in any network
+func (p *Provider) GetSSIDs() []string {
+	return nil
+}
+
+// GetStops returns nil, gpsd knows nothing about the trip
+func (p *Provider) GetStops() []types.Stop {
+	return nil
+}
+
+func (p *Provider) GetTrainInfo(s string) string {
+	return ""
+}
@@ -0,0 +1,48 @@
+package gpsd
+
+// Watch is the argument of the ?WATCH command
+type Watch struct {
+	Enable bool `json:"enable"`
+	JSON   bool `json:"json"`
+}
+
+// Report is the part all reports of gpsd have in common, Class tells their type
+type Report struct {
+	Class string `json:"class"`
+}
+
+// Version is the first report gpsd sends after connecting
+type Version struct {
+	Class      string `json:"class"`
+	Release    string `json:"release"`
+	ProtoMajor int    `json:"proto_major"`
+	ProtoMinor int    `json:"proto_minor"`
+}
+
+// Modes of a TPV report
+const (
+	ModeUnknown = 0
+	ModeNoFix   = 1
+	Mode2D      = 2
+	Mode3D      = 3
+)
+
+// TPV is the time-position-velocity report of a GPS receiver
+type TPV struct {
+	Class  string `json:"class"`
+	Device string `json:"device,omitempty"`
+	Mode   int    `json:"mode"`
+	// Time is the time of the fix in RFC 3339 format, it is missing without a fix
+	Time string  `json:"time,omitempty"`
+	Lat  float64 `json:"lat,omitempty"`
+	Lon  float64 `json:"lon,omitempty"`
+	Alt  float64 `json:"alt,omitempty"`
+	// Speed is the speed over ground in m/s
+	Speed float64 `json:"speed,omitempty"`
+	Track float64 `json:"track,omitempty"`
+}
+
+// HasFix returns true if the report contains a position
+func (t TPV) HasFix() bool {
+	return t.Mode >= Mode2D
+}
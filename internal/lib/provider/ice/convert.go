@@ -0,0 +1,143 @@
+package ice
+
+import (
+	"fmt"
+	"time"
+	"trk/internal/lib/types"
+)
+
+// ConvertStatus maps the portal's status and trip responses to a types.Status
+func ConvertStatus(status Status, trip *Trip) types.Status {
+	result := types.Status{
+		Train: types.Train{
+			Id:            status.Tzn,
+			Series:        status.Series,
+			SeriesDisplay: trainSeries[status.Series],
+		},
+		Speed:     status.Speed / 3.6, // the portal reports km/h
+		Timestamp: time.Unix(status.ServerTime/1000, 0),
+		Location: types.Location{
+			Latitude:  status.Latitude,
+			Longitude: status.Longitude,
+		},
+		GPSLost: GPSStatus(status.GpsStatus) != GPSStatusValid,
+		Connectivity: types.Connectivity{
+			CurrentState: status.Connectivity.CurrentState,
+			NextState:    status.Connectivity.NextState,
+			NextIn:       time.Duration(status.Connectivity.RemainingTimeSeconds) * time.Second,
+		},
+	}
+	if trip == nil {
+		return result
+	}
+	result.Train.DisplayName = fmt.Sprintf("%s %s", trip.Trip.TrainType, trip.Trip.Vzn)
+	result.Train.LookupString = fmt.Sprintf("%s %s", trip.Trip.TrainType, trip.Trip.Vzn)
+	result.Train.Type = trip.Trip.TrainType
+	result.Train.Line = trip.Trip.Vzn
+	result.Delay = time.Duration(getNextDelay(trip)) * time.Second
+	result.NextStop = getNextStop(trip)
+	return result
+}
+
+// ConvertStops maps all stops of the trip to types.Stop
+func ConvertStops(trip *Trip) []types.Stop {
+	if trip == nil {
+		return nil
+	}
+	stops := make([]types.Stop, 0, len(trip.Trip.Stops))
+	for _, stop := range trip.Trip.Stops {
+		stops = append(stops, convertStop(stop))
+	}
+	return stops
+}
+
+func convertStop(stop TripStop) types.Stop {
+	timetable := stop.Timetable
+	result := types.Stop{
+		Id:             stop.Station.EvaNr,
+		Name:           stop.Station.Name,
+		Track:          stop.Track.Actual,
+		ScheduledTrack: stop.Track.Scheduled,
+		Location: types.Location{
+			Latitude:  stop.Station.Geocoordinates.Latitude,
+			Longitude: stop.Station.Geocoordinates.Longitude,
+		},
+		ScheduledArrivalTime:   unixMillis(timetable.ScheduledArrivalTime),
+		ScheduledDepartureTime: unixMillis(timetable.ScheduledDepartureTime),
+		Passed:                 stop.Info.Passed,
+		Cancelled:              stop.Info.Status == StopStatusCancelled,
+	}
+	for _, reason := range stop.DelayReasons {
+		result.DelayReasons = append(result.DelayReasons, types.DelayReason{Code: reason.Code, Text: reason.Text})
+	}
+	result.ArrivalTime = unixMillis(timetable.ActualArrivalTime)
+	if result.ArrivalTime.IsZero() {
+		result.ArrivalTime = result.ScheduledArrivalTime
+	}
+	result.DepartureTime = unixMillis(timetable.ActualDepartureTime)
+	if result.DepartureTime.IsZero() {
+		result.DepartureTime = result.ScheduledDepartureTime
+	}
+	return result
+}
+
+// ConvertConnections maps the onward connections of a stop to types.Connection
+func ConvertConnections(connections Connections) []types.Connection {
+	result := make([]types.Connection, 0, len(connections.Connections))
+	for _, connection := range connections.Connections {
+		name := fmt.Sprintf("%s %s", connection.TrainType, connection.Vzn)
+		c := types.Connection{
+			Train: types.Train{
+				Id:           connection.TrainNumber,
+				DisplayName:  name,
+				LookupString: name,
+				Type:         connection.TrainType,
+				Line:         connection.Vzn,
+			},
+			Destination:            connection.Station.Name,
+			ScheduledDepartureTime: unixMillis(connection.Timetable.ScheduledDepartureTime),
+			DepartureTime:          unixMillis(connection.Timetable.ActualDepartureTime),
+			Track:                  connection.Track.Actual,
+			ScheduledTrack:         connection.Track.Scheduled,
+			Cancelled:              connection.Info.Status == StopStatusCancelled,
+		}
+		if c.DepartureTime.IsZero() {
+			c.DepartureTime = c.ScheduledDepartureTime
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+func unixMillis(ms *int64) time.Time {
+	if ms == nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(*ms)
+}
+
+func getNextDelay(trip *Trip) int64 {
+	for _, stop := range trip.Trip.Stops {
+		if stop.Info.Passed {
+			continue
+		}
+		if stop.Timetable.ActualArrivalTime != nil && stop.Timetable.ScheduledArrivalTime != nil {
+			delay := *stop.Timetable.ActualArrivalTime - *stop.Timetable.ScheduledArrivalTime
+			if delay > 0 {
+				return delay / 1000
+			}
+		}
+
+	}
+	return 0
+}
+
+func getNextStop(trip *Trip) types.Stop {
+	for _, stop := range trip.Trip.Stops {
+		if stop.Info.Passed {
+			continue
+		}
+		return convertStop(stop)
+	}
+	return types.Stop{}
+}
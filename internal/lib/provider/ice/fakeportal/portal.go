@@ -0,0 +1,423 @@
+// Package fakeportal serves a scripted iceportal.de for development and tests.
+//
+// The ride is described by a Scenario: the train moves along the stops according
+// to the timetable plus the current delay, and the scenario's steps add delays,
+// change tracks, cancel stops or let the GPS and internet drop out.
+package fakeportal
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+	"trk/internal/lib/provider/ice"
+)
+
+//go:embed *.example.json
+var examples embed.FS
+
+// Resolver resolves every host to ice.PortalAddress, so ice.Provider accepts the fake portal
+type Resolver struct{}
+
+func (Resolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return []net.IPAddr{{IP: ice.PortalAddress}}, nil
+}
+
+// Portal is a http.Handler serving the portal API for a scenario.
+// The scenario time only moves by calling Advance or Seek, or in real time after calling Play.
+type Portal struct {
+	scenario *Scenario
+	mux      *http.ServeMux
+
+	mu       sync.Mutex
+	elapsed  time.Duration
+	playing  time.Time
+	speed    float64
+	distance []float64
+}
+
+func NewPortal(scenario *Scenario) *Portal {
+	p := &Portal{
+		scenario: scenario,
+		mux:      http.NewServeMux(),
+	}
+	p.distance = make([]float64, len(scenario.Stops))
+	for i := 1; i < len(scenario.Stops); i++ {
+		p.distance[i] = p.distance[i-1] + haversine(scenario.Stops[i-1], scenario.Stops[i])
+	}
+	p.mux.HandleFunc("/api1/rs/status", p.handleStatus)
+	p.mux.HandleFunc("/api1/rs/tripInfo/trip", p.handleTrip)
+	p.mux.HandleFunc("/api1/rs/tripInfo/connection/", p.handleConnections)
+	p.mux.HandleFunc("/bap/api/articles", serveExample("bap_products.example.json"))
+	p.mux.HandleFunc("/bap/api/availabilities", serveExample("bap_availabilities.example.json"))
+	return p
+}
+
+func (p *Portal) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mux.ServeHTTP(w, r)
+}
+
+// Advance moves the scenario time forward by d
+func (p *Portal) Advance(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.elapsed += d
+}
+
+// Seek sets the scenario time to the offset d
+func (p *Portal) Seek(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.elapsed = d
+	if !p.playing.IsZero() {
+		p.playing = time.Now()
+	}
+}
+
+// Play lets the scenario time run with the given speed, 1 is real time
+func (p *Portal) Play(speed float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.elapsed = p.now()
+	p.playing = time.Now()
+	p.speed = speed
+}
+
+// Now returns the current scenario time as offset to Scenario.Start
+func (p *Portal) Now() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.now()
+}
+
+func (p *Portal) now() time.Duration {
+	if p.playing.IsZero() {
+		return p.elapsed
+	}
+	return p.elapsed + time.Duration(float64(time.Since(p.playing))*p.speed)
+}
+
+// Server is a Portal running on a local httptest.Server
+type Server struct {
+	*httptest.Server
+	*Portal
+}
+
+func NewServer(scenario *Scenario) *Server {
+	portal := NewPortal(scenario)
+	return &Server{
+		Server: httptest.NewServer(portal),
+		Portal: portal,
+	}
+}
+
+// Provider returns an ice.Provider talking to the server
+func (s *Server) Provider() *ice.Provider {
+	p := ice.NewICEProvider()
+	p.BaseURL = s.URL
+	p.Resolver = Resolver{}
+	p.SSIDs = nil
+	return p
+}
+
+// state is the result of all steps up to a point in time
+type state struct {
+	// arrivalDelays and departureDelays hold the delay of every stop
+	arrivalDelays   []time.Duration
+	departureDelays []time.Duration
+	gps             bool
+	gpsLostAt       time.Duration
+	internet        string
+	nextInternet    string
+	nextIn          time.Duration
+	tracks          map[string]string
+	cancelled       map[string]bool
+	delayReasons    []ice.DelayReason
+}
+
+func (p *Portal) state(at time.Duration) state {
+	s := state{
+		arrivalDelays:   make([]time.Duration, len(p.scenario.Stops)),
+		departureDelays: make([]time.Duration, len(p.scenario.Stops)),
+		gps:             true,
+		internet:        "HIGH",
+		tracks:          map[string]string{},
+		cancelled:       map[string]bool{},
+	}
+	for _, step := range p.scenario.Steps {
+		stepAt := time.Duration(step.At)
+		if stepAt > at {
+			if step.Internet != nil && s.nextInternet == "" && *step.Internet != s.internet {
+				s.nextInternet = *step.Internet
+				s.nextIn = stepAt - at
+			}
+			continue
+		}
+		if step.Delay != nil {
+			s.setDelay(p, stepAt, time.Duration(*step.Delay))
+		}
+		if step.GPS != nil {
+			if s.gps && !*step.GPS {
+				s.gpsLostAt = stepAt
+			}
+			s.gps = *step.GPS
+		}
+		if step.Internet != nil {
+			s.internet = *step.Internet
+		}
+		for evaNr, track := range step.Tracks {
+			s.tracks[evaNr] = track
+		}
+		for _, evaNr := range step.Cancelled {
+			s.cancelled[evaNr] = true
+		}
+		if step.DelayReasons != nil {
+			s.delayReasons = step.DelayReasons
+		}
+	}
+	if s.nextInternet == "" {
+		s.nextInternet = s.internet
+	}
+	return s
+}
+
+// setDelay changes the delay of all arrivals and departures after the offset `at`,
+// so the train never moves back to a stop it has already reached or left
+func (s *state) setDelay(p *Portal, at time.Duration, delay time.Duration) {
+	arrivals := make([]bool, len(s.arrivalDelays))
+	departures := make([]bool, len(s.departureDelays))
+	for i := range p.scenario.Stops {
+		arrivals[i] = p.arrival(i, *s) > at
+		departures[i] = p.departure(i, *s) > at
+	}
+	for i := range p.scenario.Stops {
+		if arrivals[i] {
+			s.arrivalDelays[i] = delay
+		}
+		if departures[i] {
+			s.departureDelays[i] = delay
+		}
+	}
+}
+
+func (p *Portal) arrival(i int, s state) time.Duration {
+	stop := p.scenario.Stops[i]
+	if stop.Arrival == nil {
+		return p.departure(i, s)
+	}
+	return time.Duration(*stop.Arrival) + s.arrivalDelays[i]
+}
+
+func (p *Portal) departure(i int, s state) time.Duration {
+	stop := p.scenario.Stops[i]
+	if stop.Departure == nil {
+		return p.arrival(i, s)
+	}
+	return time.Duration(*stop.Departure) + s.departureDelays[i]
+}
+
+// position returns where the train is at the offset `at`, its speed in km/h
+// and the index of the last stop it departed from or stands at
+func (p *Portal) position(at time.Duration, s state) (lat, lon, speed, distance float64, last int) {
+	stops := p.scenario.Stops
+	for i := 0; i < len(stops)-1; i++ {
+		departure := p.departure(i, s)
+		if at < departure {
+			return stops[i].Latitude, stops[i].Longitude, 0, p.distance[i], i
+		}
+		arrival := p.arrival(i+1, s)
+		if at < arrival {
+			fraction := float64(at-departure) / float64(arrival-departure)
+			lat = stops[i].Latitude + (stops[i+1].Latitude-stops[i].Latitude)*fraction
+			lon = stops[i].Longitude + (stops[i+1].Longitude-stops[i].Longitude)*fraction
+			segment := p.distance[i+1] - p.distance[i]
+			speed = segment / (arrival - departure).Hours() / 1000
+			return lat, lon, speed, p.distance[i] + segment*fraction, i
+		}
+	}
+	last = len(stops) - 1
+	return stops[last].Latitude, stops[last].Longitude, 0, p.distance[last], last
+}
+
+func (p *Portal) handleStatus(w http.ResponseWriter, r *http.Request) {
+	at := p.Now()
+	s := p.state(at)
+	lat, lon, speed, _, _ := p.position(at, s)
+	gpsStatus := string(ice.GPSStatusValid)
+	if !s.gps {
+		lat, lon, _, _, _ = p.position(s.gpsLostAt, p.state(s.gpsLostAt))
+		gpsStatus = string(ice.GPSStatusLastKnown)
+	}
+	status := ice.Status{
+		Connection:   true,
+		ServiceLevel: "AVAILABLE_SERVICE",
+		GpsStatus:    gpsStatus,
+		Internet:     s.internet,
+		Latitude:     lat,
+		Longitude:    lon,
+		Series:       p.scenario.Train.Series,
+		ServerTime:   p.scenario.Start.Add(at).UnixMilli(),
+		Speed:        math.Round(speed),
+		TrainType:    p.scenario.Train.TrainType,
+		Tzn:          p.scenario.Train.Tzn,
+		WagonClass:   "SECOND",
+	}
+	status.Connectivity.CurrentState = s.internet
+	status.Connectivity.NextState = s.nextInternet
+	status.Connectivity.RemainingTimeSeconds = int(s.nextIn.Seconds())
+	writeJSON(w, status)
+}
+
+func (p *Portal) handleTrip(w http.ResponseWriter, r *http.Request) {
+	at := p.Now()
+	s := p.state(at)
+	_, _, _, distance, last := p.position(at, s)
+	stops := p.scenario.Stops
+	start := p.scenario.Start
+
+	trip := ice.Trip{}
+	trip.Trip.TripDate = start.Format("2006-01-02")
+	trip.Trip.TrainType = p.scenario.Train.TrainType
+	trip.Trip.Vzn = p.scenario.Train.Vzn
+	trip.Trip.ActualPosition = int(distance)
+	trip.Trip.DistanceFromLastStop = int(distance - p.distance[last])
+	trip.Trip.TotalDistance = int(p.distance[len(stops)-1])
+	trip.Trip.StopInfo.FinalStationName = stops[len(stops)-1].Name
+	trip.Trip.StopInfo.FinalStationEvaNr = stops[len(stops)-1].EvaNr
+	trip.Trip.StopInfo.ActualLast = stops[last].EvaNr
+	trip.Trip.StopInfo.ActualLastStarted = stops[last].EvaNr
+	trip.Connection.Conflict = "NO_CONFLICT"
+
+	for i, stop := range stops {
+		passed := p.departure(i, s) <= at
+		if i == len(stops)-1 {
+			passed = p.arrival(i, s) <= at
+		}
+		if !passed && trip.Trip.StopInfo.ActualNext == "" {
+			trip.Trip.StopInfo.ActualNext = stop.EvaNr
+			trip.Trip.StopInfo.ScheduledNext = stop.EvaNr
+		}
+		positionStatus := "future"
+		if passed {
+			positionStatus = "passed"
+		}
+
+		ts := ice.TripStop{}
+		ts.Station.EvaNr = stop.EvaNr
+		ts.Station.Name = stop.Name
+		ts.Station.Geocoordinates.Latitude = stop.Latitude
+		ts.Station.Geocoordinates.Longitude = stop.Longitude
+		if stop.Arrival != nil {
+			ts.Timetable.ScheduledArrivalTime = millis(start, time.Duration(*stop.Arrival))
+			ts.Timetable.ActualArrivalTime = millis(start, p.arrival(i, s))
+			ts.Timetable.ShowActualArrivalTime = boolPtr(true)
+			ts.Timetable.ArrivalDelay = formatDelay(s.arrivalDelays[i])
+		}
+		if stop.Departure != nil {
+			ts.Timetable.ScheduledDepartureTime = millis(start, time.Duration(*stop.Departure))
+			ts.Timetable.ActualDepartureTime = millis(start, p.departure(i, s))
+			ts.Timetable.ShowActualDepartureTime = boolPtr(true)
+			ts.Timetable.DepartureDelay = formatDelay(s.departureDelays[i])
+		}
+		ts.Track.Scheduled = stop.Track
+		ts.Track.Actual = stop.Track
+		if track, ok := s.tracks[stop.EvaNr]; ok {
+			ts.Track.Actual = track
+		}
+		if s.cancelled[stop.EvaNr] {
+			ts.Info.Status = ice.StopStatusCancelled
+		}
+		if !passed {
+			ts.DelayReasons = s.delayReasons
+		}
+		ts.Info.Passed = passed
+		ts.Info.PositionStatus = positionStatus
+		if i > 0 {
+			ts.Info.Distance = int(p.distance[i] - p.distance[i-1])
+		}
+		ts.Info.DistanceFromStart = int(p.distance[i])
+		trip.Trip.Stops = append(trip.Trip.Stops, ts)
+	}
+	writeJSON(w, trip)
+}
+
+func (p *Portal) handleConnections(w http.ResponseWriter, r *http.Request) {
+	evaNr := strings.TrimPrefix(r.URL.Path, "/api1/rs/tripInfo/connection/")
+	start := p.scenario.Start
+	connections := ice.Connections{
+		Connections:    []ice.Connection{},
+		RequestedEvaNr: evaNr,
+	}
+	for _, connection := range p.scenario.Connections[evaNr] {
+		c := ice.Connection{
+			TrainType:   connection.TrainType,
+			Vzn:         connection.Vzn,
+			TrainNumber: connection.TrainNumber,
+			Conflict:    "NO_CONFLICT",
+		}
+		c.Station.Name = connection.Destination
+		c.Timetable.ScheduledDepartureTime = millis(start, time.Duration(connection.Departure))
+		c.Timetable.ActualDepartureTime = millis(start, time.Duration(connection.Departure+connection.Delay))
+		c.Timetable.ShowActualDepartureTime = boolPtr(true)
+		c.Timetable.DepartureDelay = formatDelay(time.Duration(connection.Delay))
+		c.Track.Scheduled = connection.Track
+		c.Track.Actual = connection.Track
+		if connection.Cancelled {
+			c.Info.Status = ice.StopStatusCancelled
+		}
+		connections.Connections = append(connections.Connections, c)
+	}
+	writeJSON(w, connections)
+}
+
+func serveExample(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := examples.ReadFile(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func millis(start time.Time, offset time.Duration) *int64 {
+	ms := start.Add(offset).UnixMilli()
+	return &ms
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func formatDelay(delay time.Duration) string {
+	if delay < time.Minute {
+		return ""
+	}
+	return fmt.Sprintf("+%d", int(delay.Minutes()))
+}
+
+// haversine returns the distance between two stops in meters
+func haversine(a, b ScenarioStop) float64 {
+	const earthRadius = 6371000
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,102 @@
+package fakeportal
+
+import (
+	"context"
+	"testing"
+	"time"
+	"trk/internal/lib/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProviderAgainstFakePortal(t *testing.T) {
+	scenario, err := LoadScenario("testdata/bamberg-muenchen.json")
+	if !assert.NoError(t, err) {
+		return
+	}
+	srv := NewServer(scenario)
+	defer srv.Close()
+
+	p := srv.Provider()
+	p.PollInterval = time.Millisecond
+	ok, err := p.Probe()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	statusChan := make(chan types.Status)
+	done := make(chan error)
+	go func() {
+		done <- p.Run(ctx, statusChan)
+	}()
+	next := func(at time.Duration) types.Status {
+		srv.Seek(at)
+		// the first status may have been fetched before seeking
+		<-statusChan
+		return <-statusChan
+	}
+
+	status := next(0)
+	assert.Equal(t, "ICE9048", status.Train.Id)
+	assert.Equal(t, "ICE 505", status.Train.DisplayName)
+	assert.Equal(t, "Bamberg", status.NextStop.Name)
+	assert.Equal(t, scenario.Start.Unix(), status.Timestamp.Unix())
+	assert.Equal(t, 0.0, status.Speed)
+
+	status = next(15 * time.Minute)
+	assert.Equal(t, 4*time.Minute, status.Delay)
+	assert.Greater(t, status.Speed, 0.0)
+	assert.Equal(t, "Erlangen", status.NextStop.Name)
+
+	status = next(31 * time.Minute)
+	assert.Equal(t, "Nürnberg Hbf", status.NextStop.Name)
+	erlangen := types.Trip(p.GetStops()).GetStop("8001844")
+	if assert.NotNil(t, erlangen) {
+		assert.True(t, erlangen.Passed)
+		assert.Equal(t, "5", erlangen.Track)
+	}
+
+	connections, err := p.GetConnections(ctx, "8000261")
+	if assert.NoError(t, err) && assert.Len(t, connections, 3) {
+		assert.Equal(t, "RE 1", connections[0].Train.DisplayName)
+		assert.Equal(t, "Salzburg Hbf", connections[0].Destination)
+		assert.Equal(t, "26", connections[0].Track)
+		assert.True(t, scenario.Start.Add(118*time.Minute).Equal(connections[0].DepartureTime))
+		assert.Equal(t, 3*time.Minute, connections[2].Delay())
+	}
+	connections, err = p.GetConnections(ctx, "8000025")
+	assert.NoError(t, err)
+	assert.Empty(t, connections)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestDelayKeepsPassedStops(t *testing.T) {
+	at := func(d time.Duration) *Duration {
+		return (*Duration)(&d)
+	}
+	scenario := &Scenario{
+		Start: time.Date(2023, 2, 25, 10, 0, 0, 0, time.UTC),
+		Stops: []ScenarioStop{
+			{EvaNr: "1", Name: "A", Departure: at(0)},
+			{EvaNr: "2", Name: "B", Latitude: 1, Arrival: at(10 * time.Minute), Departure: at(12 * time.Minute)},
+			{EvaNr: "3", Name: "C", Latitude: 2, Arrival: at(30 * time.Minute)},
+		},
+		Steps: []Step{{At: Duration(13 * time.Minute), Delay: at(5 * time.Minute)}},
+	}
+	p := NewPortal(scenario)
+
+	s := p.state(14 * time.Minute)
+	assert.Equal(t, 12*time.Minute, p.departure(1, s))
+	assert.Equal(t, 35*time.Minute, p.arrival(2, s))
+	_, _, _, _, last := p.position(14*time.Minute, s)
+	assert.Equal(t, 1, last)
+
+	// standing at B, only the departure is delayed
+	scenario.Steps[0].At = Duration(11 * time.Minute)
+	s = p.state(11 * time.Minute)
+	assert.Equal(t, 10*time.Minute, p.arrival(1, s))
+	assert.Equal(t, 17*time.Minute, p.departure(1, s))
+}
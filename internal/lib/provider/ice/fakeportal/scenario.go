@@ -0,0 +1,103 @@
+package fakeportal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+	"trk/internal/lib/provider/ice"
+)
+
+// Duration is a time.Duration which is written as "5m30s" in scenario files
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Scenario describes a scripted train ride. All times are offsets to Start.
+type Scenario struct {
+	Start time.Time      `json:"start"`
+	Train ScenarioTrain  `json:"train"`
+	Stops []ScenarioStop `json:"stops"`
+	// Steps change the state of the ride, every step keeps its values until a later step overrides them
+	Steps []Step `json:"steps"`
+	// Connections are the onward trains at a stop, keyed by its evaNr
+	Connections map[string][]ScenarioConnection `json:"connections"`
+}
+
+type ScenarioTrain struct {
+	Tzn       string `json:"tzn"`
+	TrainType string `json:"trainType"`
+	Vzn       string `json:"vzn"`
+	Series    string `json:"series"`
+}
+
+type ScenarioStop struct {
+	EvaNr     string    `json:"evaNr"`
+	Name      string    `json:"name"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Arrival   *Duration `json:"arrival"`
+	Departure *Duration `json:"departure"`
+	Track     string    `json:"track"`
+}
+
+type ScenarioConnection struct {
+	TrainType   string   `json:"trainType"`
+	Vzn         string   `json:"vzn"`
+	TrainNumber string   `json:"trainNumber"`
+	Destination string   `json:"destination"`
+	Departure   Duration `json:"departure"`
+	Delay       Duration `json:"delay"`
+	Track       string   `json:"track"`
+	Cancelled   bool     `json:"cancelled"`
+}
+
+type Step struct {
+	At Duration `json:"at"`
+	// Delay replaces the delay of all arrivals and departures that have not happened yet
+	Delay *Duration `json:"delay"`
+	// GPS false freezes the reported position and reports it as last known position
+	GPS      *bool   `json:"gps"`
+	Internet *string `json:"internet"`
+	// Tracks maps the evaNr of a stop to its new actual track
+	Tracks    map[string]string `json:"tracks"`
+	Cancelled []string          `json:"cancelled"`
+	// DelayReasons replace the reasons of all stops that have not been passed yet
+	DelayReasons []ice.DelayReason `json:"delayReasons"`
+}
+
+// LoadScenario reads a scenario file
+func LoadScenario(path string) (*Scenario, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scenario := &Scenario{}
+	if err = json.NewDecoder(f).Decode(scenario); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(scenario.Stops) < 2 {
+		return nil, fmt.Errorf("%s: a scenario needs at least two stops", path)
+	}
+	sort.SliceStable(scenario.Steps, func(i, j int) bool {
+		return scenario.Steps[i].At < scenario.Steps[j].At
+	})
+	return scenario, nil
+}
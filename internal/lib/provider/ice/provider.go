@@ -2,229 +2,69 @@ package ice
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"log"
 	"net"
-	"net/http"
-	"time"
+	"net/url"
+	"sync"
+	"trk/internal/lib/provider/portal"
 	"trk/internal/lib/types"
 )
 
+// PortalAddress is the address iceportal.de resolves to inside the train's WiFi
+var PortalAddress = net.IPv4(172, 18, 1, 110)
+
 type Provider struct {
-	status         bool
-	ResolveTimeout int64
-	ValidAddressed []net.IP
-	client         *http.Client
-	trip           *Trip
-}
+	*portal.Client
 
-func (p *Provider) GetTrainInfo(s string) string {
-	//TODO implement me
-	panic("implement me")
+	mu   sync.Mutex
+	trip *Trip
 }
 
 func NewICEProvider() *Provider {
-	return &Provider{
-		status:         false,
-		ResolveTimeout: 5,
-		ValidAddressed: []net.IP{net.IPv4(172, 18, 1, 110)},
-		client: &http.Client{
-			Timeout: 5 * time.Second,
-		},
+	client := portal.NewClient("https://iceportal.de")
+	// outside the train iceportal.de resolves to the public website
+	client.ValidAddress = func(ip net.IP) bool {
+		return ip.Equal(PortalAddress)
 	}
+	client.SSIDs = []string{"WIFIonICE", "WIFI@DB"}
+	return &Provider{Client: client}
 }
 
+// Probe checks whether the portal resolves to PortalAddress and returns a status
 func (p *Provider) Probe() (bool, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.ResolveTimeout)*time.Second)
-	defer cancel()
-	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, "iceportal.de")
-	if err != nil {
-		return false, err
-	}
-	addressValid := false
-Outer:
-	for _, addr := range addrs {
-		for _, vaddr := range p.ValidAddressed {
-			if addr.IP.Equal(vaddr) {
-				addressValid = true
-				break Outer
-			}
-		}
-	}
-	if !addressValid {
-		log.Printf("No Valid IP Address returned, are you in the correct wifi? %v\n", addrs)
-		return false, nil
-	}
-
-	return p.testAPI(), nil
-}
-
-func (p *Provider) testAPI() bool {
-	resp, err := p.client.Head("https://iceportal.de/api1/rs/status")
-	if err != nil {
-		log.Printf("error fetching API %#v\n", err.Error())
-		return false
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		return true
-	}
-
-	log.Printf("API returned %d\n", resp.StatusCode)
-	return false
+	return p.Client.Probe("/api1/rs/status", &Status{})
 }
 
-func (p *Provider) Run(statusChan chan types.Status) {
-	ticker := time.NewTicker(5 * time.Second)
-	quit := make(chan struct{})
-	var err error
-	var status Status
-	for {
-		select {
-		case <-ticker.C:
-			status, err = p.getStatus()
-			if err != nil {
-				log.Printf("error fetching status: %s", err.Error())
-			}
-			err = p.fetchTrip()
-			trainSeriesDisplay := trainSeries[status.Series]
-
-			statusChan <- types.Status{
-				Train: types.Train{
-					Id:            status.Tzn,
-					DisplayName:   fmt.Sprintf("%s %s", p.trip.Trip.TrainType, p.trip.Trip.Vzn),
-					LookupString:  fmt.Sprintf("%s %s", p.trip.Trip.TrainType, p.trip.Trip.Vzn),
-					Type:          p.trip.Trip.TrainType,
-					Line:          p.trip.Trip.Vzn,
-					Series:        status.Series,
-					SeriesDisplay: trainSeriesDisplay,
-				},
-				Speed:     int64(status.Speed * 3.6),
-				Timestamp: time.Unix(status.ServerTime/1000, 0),
-				Delay:     time.Duration(p.getNextDelay()) * time.Second,
-				Location: types.Location{
-					Latitude:  status.Latitude,
-					Longitude: status.Longitude,
-				},
-				NextStop: p.getNextStop(),
-			}
-		case <-quit:
-			break
-		}
-	}
+func (p *Provider) Run(ctx context.Context, statusChan chan types.Status) error {
+	return p.Poll(ctx, statusChan, p.fetch)
 }
 
-func (p *Provider) getStatus() (Status, error) {
+// fetch fetches the status and the trip and converts them into a status
+func (p *Provider) fetch(ctx context.Context) (types.Status, error) {
 	status := Status{}
-	resp, err := p.client.Get("https://iceportal.de/api1/rs/status")
-	if err != nil {
-		return status, err
+	if _, err := p.Get(ctx, "/api1/rs/status", &status); err != nil {
+		return types.Status{}, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return status, fmt.Errorf("status: %d", resp.StatusCode)
+	trip := &Trip{}
+	if _, err := p.Get(ctx, "/api1/rs/tripInfo/trip", trip); err != nil {
+		return types.Status{}, err
 	}
-
-	dec := json.NewDecoder(resp.Body)
-	err = dec.Decode(&status)
-
-	return status, err
+	p.mu.Lock()
+	p.trip = trip
+	p.mu.Unlock()
+	return ConvertStatus(status, trip), nil
 }
 
-func (p *Provider) fetchTrip() error {
-	trip := Trip{}
-	resp, err := p.client.Get("https://iceportal.de/api1/rs/tripInfo/trip")
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("trip: %d", resp.StatusCode)
+// GetConnections fetches the onward connections at the stop with the evaNr stopId
+func (p *Provider) GetConnections(ctx context.Context, stopId string) ([]types.Connection, error) {
+	connections := Connections{}
+	if _, err := p.Get(ctx, "/api1/rs/tripInfo/connection/"+url.PathEscape(stopId), &connections); err != nil {
+		return nil, err
 	}
-
-	dec := json.NewDecoder(resp.Body)
-	err = dec.Decode(&trip)
-	p.trip = &trip
-	return err
-}
-
-func (p *Provider) getNextDelay() int64 {
-
-	for _, stop := range p.trip.Trip.Stops {
-		if stop.Info.Passed {
-			continue
-		}
-		if stop.Timetable.ActualArrivalTime != nil && stop.Timetable.ScheduledArrivalTime != nil {
-			delay := *stop.Timetable.ActualArrivalTime - *stop.Timetable.ScheduledArrivalTime
-			if delay > 0 {
-				return delay / 1000
-			}
-		}
-
-	}
-	return 0
-}
-
-func (p *Provider) getNextStop() types.Stop {
-	for _, stop := range p.trip.Trip.Stops {
-		if stop.Info.Passed {
-			continue
-		}
-		arrivalTime := time.Time{}
-		if stop.Timetable.ActualArrivalTime != nil {
-			arrivalTime = time.Unix(*stop.Timetable.ActualArrivalTime/1000, 0)
-		}
-		return types.Stop{
-			Id:   stop.Station.EvaNr,
-			Name: stop.Station.Name,
-			Location: types.Location{
-				Latitude:  stop.Station.Geocoordinates.Latitude,
-				Longitude: stop.Station.Geocoordinates.Longitude,
-			},
-			ArrivalTime: arrivalTime,
-		}
-	}
-	return types.Stop{}
-}
-
-func (p *Provider) getDelay(trip Trip) int64 {
-	for _, stop := range trip.Trip.Stops {
-		if stop.Info.Passed {
-			continue
-		}
-		if stop.Timetable.ActualArrivalTime != nil && stop.Timetable.ScheduledArrivalTime != nil {
-			delay := *stop.Timetable.ActualArrivalTime - *stop.Timetable.ScheduledArrivalTime
-			if delay > 0 {
-				return delay / 1000
-			}
-		}
-	}
-	return 0
+	return ConvertConnections(connections), nil
 }
 
 func (p *Provider) GetStops() []types.Stop {
-	if p.trip == nil {
-		return nil
-	}
-	stops := make([]types.Stop, 0, len(p.trip.Trip.Stops))
-	for _, stop := range p.trip.Trip.Stops {
-		arrivalTime := time.Time{}
-		if stop.Timetable.ActualArrivalTime != nil {
-			arrivalTime = time.Unix(*stop.Timetable.ActualArrivalTime/1000, 0)
-		}
-
-		stops = append(stops, types.Stop{
-			Id:    stop.Station.EvaNr,
-			Name:  stop.Station.Name,
-			Track: stop.Track.Actual,
-			Location: types.Location{
-				Latitude:  stop.Station.Geocoordinates.Latitude,
-				Longitude: stop.Station.Geocoordinates.Longitude,
-			},
-			ArrivalTime: arrivalTime,
-			Passed:      stop.Info.Passed,
-		})
-	}
-	return stops
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return ConvertStops(p.trip)
 }
@@ -16,57 +16,85 @@ type Trip struct {
 			FinalStationName  string `json:"finalStationName"`
 			FinalStationEvaNr string `json:"finalStationEvaNr"`
 		} `json:"stopInfo"`
-		Stops []struct {
-			Station struct {
-				EvaNr          string      `json:"evaNr"`
-				Name           string      `json:"name"`
-				Code           interface{} `json:"code"`
-				Geocoordinates struct {
-					Latitude  float64 `json:"latitude"`
-					Longitude float64 `json:"longitude"`
-				} `json:"geocoordinates"`
-			} `json:"station"`
-			Timetable struct {
-				ScheduledArrivalTime    *int64 `json:"scheduledArrivalTime"`
-				ActualArrivalTime       *int64 `json:"actualArrivalTime"`
-				ShowActualArrivalTime   *bool  `json:"showActualArrivalTime"`
-				ArrivalDelay            string `json:"arrivalDelay"`
-				ScheduledDepartureTime  *int64 `json:"scheduledDepartureTime"`
-				ActualDepartureTime     *int64 `json:"actualDepartureTime"`
-				ShowActualDepartureTime *bool  `json:"showActualDepartureTime"`
-				DepartureDelay          string `json:"departureDelay"`
-			} `json:"timetable"`
-			Track struct {
-				Scheduled string `json:"scheduled"`
-				Actual    string `json:"actual"`
-			} `json:"track"`
-			Info struct {
-				Status            int    `json:"status"`
-				Passed            bool   `json:"passed"`
-				PositionStatus    string `json:"positionStatus"`
-				Distance          int    `json:"distance"`
-				DistanceFromStart int    `json:"distanceFromStart"`
-			} `json:"info"`
-			DelayReasons []struct {
-				Code string `json:"code"`
-				Text string `json:"text"`
-			} `json:"delayReasons"`
-		} `json:"stops"`
+		Stops []TripStop `json:"stops"`
 	} `json:"trip"`
-	Connection struct {
-		TrainType   interface{} `json:"trainType"`
-		Vzn         interface{} `json:"vzn"`
-		TrainNumber interface{} `json:"trainNumber"`
-		Station     interface{} `json:"station"`
-		Timetable   interface{} `json:"timetable"`
-		Track       interface{} `json:"track"`
-		Info        interface{} `json:"info"`
-		Stops       interface{} `json:"stops"`
-		Conflict    string      `json:"conflict"`
-	} `json:"connection"`
-	Active interface{} `json:"active"`
+	Connection Connection  `json:"connection"`
+	Active     interface{} `json:"active"`
 }
 
+type TripStop struct {
+	Station      Station       `json:"station"`
+	Timetable    Timetable     `json:"timetable"`
+	Track        Track         `json:"track"`
+	Info         StopInfo      `json:"info"`
+	DelayReasons []DelayReason `json:"delayReasons"`
+}
+
+type Station struct {
+	EvaNr          string      `json:"evaNr"`
+	Name           string      `json:"name"`
+	Code           interface{} `json:"code"`
+	Geocoordinates struct {
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
+	} `json:"geocoordinates"`
+}
+
+type Timetable struct {
+	ScheduledArrivalTime    *int64 `json:"scheduledArrivalTime"`
+	ActualArrivalTime       *int64 `json:"actualArrivalTime"`
+	ShowActualArrivalTime   *bool  `json:"showActualArrivalTime"`
+	ArrivalDelay            string `json:"arrivalDelay"`
+	ScheduledDepartureTime  *int64 `json:"scheduledDepartureTime"`
+	ActualDepartureTime     *int64 `json:"actualDepartureTime"`
+	ShowActualDepartureTime *bool  `json:"showActualDepartureTime"`
+	DepartureDelay          string `json:"departureDelay"`
+}
+
+type Track struct {
+	Scheduled string `json:"scheduled"`
+	Actual    string `json:"actual"`
+}
+
+type StopInfo struct {
+	Status            int    `json:"status"`
+	Passed            bool   `json:"passed"`
+	PositionStatus    string `json:"positionStatus"`
+	Distance          int    `json:"distance"`
+	DistanceFromStart int    `json:"distanceFromStart"`
+}
+
+// Connection is an onward train, Station is its destination
+type Connection struct {
+	TrainType   string     `json:"trainType"`
+	Vzn         string     `json:"vzn"`
+	TrainNumber string     `json:"trainNumber"`
+	Station     Station    `json:"station"`
+	Timetable   Timetable  `json:"timetable"`
+	Track       Track      `json:"track"`
+	Info        StopInfo   `json:"info"`
+	Stops       []TripStop `json:"stops"`
+	Conflict    string     `json:"conflict"`
+}
+
+// https://iceportal.de/api1/rs/tripInfo/connection/{evaNr}
+type Connections struct {
+	Connections    []Connection `json:"connections"`
+	RequestedEvaNr string       `json:"requestedEvaNr"`
+}
+
+type DelayReason struct {
+	Code string `json:"code"`
+	Text string `json:"text"`
+}
+
+// Values of TripStop.Info.Status
+const (
+	StopStatusNormal     = 0
+	StopStatusAdditional = 1
+	StopStatusCancelled  = 2
+)
+
 type GPSStatus string
 
 const (
@@ -0,0 +1,108 @@
+package oebb
+
+import (
+	"fmt"
+	"time"
+	"trk/internal/lib/provider/portal"
+	"trk/internal/lib/types"
+)
+
+var trainTypes = map[string]string{
+	"RJ":  "Railjet",
+	"RJX": "Railjet Xpress",
+	"NJ":  "Nightjet",
+	"EC":  "Eurocity",
+	"IC":  "Intercity",
+}
+
+// ConvertTrain maps the train of the trip to types.Train
+func ConvertTrain(combined Combined) types.Train {
+	name := fmt.Sprintf("%s %s", combined.TrainType, combined.TripNumber)
+	return types.Train{
+		Id:            combined.TrainType + combined.TripNumber,
+		DisplayName:   name,
+		LookupString:  name,
+		Type:          combined.TrainType,
+		Line:          combined.TripNumber,
+		SeriesDisplay: trainTypes[combined.TrainType],
+	}
+}
+
+// ConvertStatus maps the position and the trip to a types.Status, now is the time of the portal
+func ConvertStatus(gps GPS, combined *Combined, now time.Time) types.Status {
+	result := types.Status{
+		Speed:     gps.Speed / 3.6, // the portal reports km/h
+		Timestamp: now,
+		Location: types.Location{
+			Latitude:  gps.Latitude,
+			Longitude: gps.Longitude,
+		},
+	}
+	if combined == nil {
+		return result
+	}
+	result.Train = ConvertTrain(*combined)
+	for _, stop := range ConvertStops(combined) {
+		if !stop.Passed {
+			result.NextStop = stop
+			result.Delay = stop.Delay()
+			if result.Delay < 0 {
+				result.Delay = 0
+			}
+			break
+		}
+	}
+	return result
+}
+
+// ConvertStops maps all stations of the trip to types.Stop, the ones before the next station are passed
+func ConvertStops(combined *Combined) []types.Stop {
+	if combined == nil {
+		return nil
+	}
+	passed := false
+	for _, station := range combined.StationList {
+		if station.Id == combined.NextStation.Id {
+			passed = true
+			break
+		}
+	}
+	stops := make([]types.Stop, 0, len(combined.StationList))
+	for _, station := range combined.StationList {
+		if station.Id == combined.NextStation.Id {
+			passed = false
+		}
+		stop := convertStation(station)
+		stop.Passed = passed
+		stops = append(stops, stop)
+	}
+	return stops
+}
+
+func convertStation(station Station) types.Stop {
+	stop := types.Stop{
+		Id:   station.Id,
+		Name: station.Name.De,
+		Location: types.Location{
+			Latitude:  station.Coordinates.Latitude,
+			Longitude: station.Coordinates.Longitude,
+		},
+		ScheduledArrivalTime:   portal.ParseTime(station.Arrival.Scheduled),
+		ArrivalTime:            portal.ParseTime(station.Arrival.Forecast),
+		ScheduledDepartureTime: portal.ParseTime(station.Departure.Scheduled),
+		DepartureTime:          portal.ParseTime(station.Departure.Forecast),
+		Track:                  station.Track.Forecast,
+		ScheduledTrack:         station.Track.Scheduled,
+		Cancelled:              station.Cancelled,
+	}
+	if stop.ArrivalTime.IsZero() {
+		stop.ArrivalTime = stop.ScheduledArrivalTime
+	}
+	if stop.DepartureTime.IsZero() {
+		stop.DepartureTime = stop.ScheduledDepartureTime
+	}
+	if stop.Track == "" {
+		stop.Track = stop.ScheduledTrack
+	}
+	return stop
+}
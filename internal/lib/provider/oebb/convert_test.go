@@ -0,0 +1,84 @@
+package oebb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func station(id, name, arrival, departure string) Station {
+	s := Station{Id: id, Name: Text{De: name}}
+	s.Arrival.Scheduled = arrival
+	s.Departure.Scheduled = departure
+	return s
+}
+
+func TestConvertStops(t *testing.T) {
+	muenchen := station("8000261", "München Hbf", "", "2023-03-04T09:25:00+01:00")
+	salzburg := station("8100002", "Salzburg Hbf", "2023-03-04T10:52:00+01:00", "2023-03-04T10:56:00+01:00")
+	salzburg.Departure.Forecast = "2023-03-04T11:02:00+01:00"
+	linz := station("8100013", "Linz Hbf", "2023-03-04T12:10:00+01:00", "2023-03-04T12:13:00+01:00")
+	linz.Track = Track{Scheduled: "4", Forecast: "5"}
+	wien := station("8103000", "Wien Hbf", "2023-03-04T13:30:00+01:00", "")
+	wien.Track.Scheduled = "9"
+	wien.Cancelled = true
+	combined := &Combined{
+		TrainType:   "RJX",
+		TripNumber:  "66",
+		NextStation: StationRef{Id: linz.Id},
+		StationList: []Station{muenchen, salzburg, linz, wien},
+	}
+
+	stops := ConvertStops(combined)
+	if assert.Len(t, stops, 4) {
+		assert.True(t, stops[0].Passed)
+		assert.True(t, stops[0].ArrivalTime.IsZero())
+		assert.True(t, stops[1].Passed)
+		assert.Equal(t, 6*time.Minute, stops[1].DepartureDelay())
+		assert.Equal(t, time.Duration(0), stops[1].ArrivalDelay())
+		assert.False(t, stops[2].Passed)
+		assert.True(t, stops[2].TrackChanged())
+		assert.Equal(t, "9", stops[3].Track)
+		assert.True(t, stops[3].Cancelled)
+		assert.True(t, stops[3].DepartureTime.IsZero())
+	}
+	assert.Nil(t, ConvertStops(nil))
+
+	// a next station which is not part of the list passes nothing
+	combined.NextStation.Id = "8100173"
+	for _, stop := range ConvertStops(combined) {
+		assert.False(t, stop.Passed)
+	}
+}
+
+func TestConvertStatus(t *testing.T) {
+	linz := station("8100013", "Linz Hbf", "2023-03-04T12:10:00+01:00", "2023-03-04T12:13:00+01:00")
+	linz.Arrival.Forecast = "2023-03-04T12:16:00+01:00"
+	combined := &Combined{
+		TrainType:   "RJX",
+		TripNumber:  "66",
+		NextStation: StationRef{Id: linz.Id},
+		StationList: []Station{linz},
+	}
+	now := time.Date(2023, 3, 4, 11, 0, 0, 0, time.UTC)
+
+	status := ConvertStatus(GPS{Latitude: 48.214402, Longitude: 14.108231, Speed: 72}, combined, now)
+	assert.Equal(t, "RJX66", status.Train.Id)
+	assert.Equal(t, "RJX 66", status.Train.DisplayName)
+	assert.Equal(t, "Railjet Xpress", status.Train.SeriesDisplay)
+	assert.Equal(t, 20.0, status.Speed)
+	assert.Equal(t, now, status.Timestamp)
+	assert.Equal(t, "Linz Hbf", status.NextStop.Name)
+	assert.Equal(t, 6*time.Minute, status.Delay)
+
+	// early trains are not reported as negative delay
+	linz.Arrival.Forecast = "2023-03-04T12:08:00+01:00"
+	combined.StationList = []Station{linz}
+	assert.Equal(t, time.Duration(0), ConvertStatus(GPS{}, combined, now).Delay)
+
+	// without the trip only the position is known
+	status = ConvertStatus(GPS{Latitude: 48.214402}, nil, now)
+	assert.Empty(t, status.Train.Id)
+	assert.Equal(t, 48.214402, status.Location.Latitude)
+}
@@ -0,0 +1,148 @@
+// Package fakerailnet serves recorded railnet.oebb.at responses, so the oebb provider can be
+// developed and tested without being on the train.
+//
+// A recording is a directory of snapshots, each made of the responses of both endpoints:
+//
+//	001_gps.json       // /api/gps
+//	001_combined.json  // /assets/modules/fis/combined.json
+package fakerailnet
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"trk/internal/lib/provider/oebb"
+)
+
+// Resolver resolves every host to the loopback address
+type Resolver struct{}
+
+func (Resolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return []net.IPAddr{{IP: net.IPv4(127, 0, 0, 1)}}, nil
+}
+
+// Snapshot are the responses of the portal at one point in time
+type Snapshot struct {
+	GPS      []byte
+	Combined []byte
+}
+
+// LoadSnapshots reads the snapshots of a recording in the order of their names
+func LoadSnapshots(dir string) ([]Snapshot, error) {
+	gpsFiles, err := filepath.Glob(filepath.Join(dir, "*_gps.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(gpsFiles)
+	snapshots := make([]Snapshot, 0, len(gpsFiles))
+	for _, gpsFile := range gpsFiles {
+		snapshot := Snapshot{}
+		if snapshot.GPS, err = os.ReadFile(gpsFile); err != nil {
+			return nil, err
+		}
+		combinedFile := strings.TrimSuffix(gpsFile, "_gps.json") + "_combined.json"
+		if snapshot.Combined, err = os.ReadFile(combinedFile); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("%s: no snapshots found", dir)
+	}
+	return snapshots, nil
+}
+
+// Railnet is an http.Handler serving one snapshot after the other
+type Railnet struct {
+	mux       *http.ServeMux
+	mu        sync.Mutex
+	snapshots []Snapshot
+	current   int
+}
+
+func NewRailnet(snapshots []Snapshot) *Railnet {
+	r := &Railnet{
+		mux:       http.NewServeMux(),
+		snapshots: snapshots,
+	}
+	r.mux.HandleFunc("/api/gps", r.serve(func(s Snapshot) []byte { return s.GPS }))
+	r.mux.HandleFunc("/assets/modules/fis/combined.json", r.serve(func(s Snapshot) []byte { return s.Combined }))
+	return r
+}
+
+func (r *Railnet) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mux.ServeHTTP(w, req)
+}
+
+// Next moves to the next snapshot, it returns false if the current one is the last
+func (r *Railnet) Next() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current == len(r.snapshots)-1 {
+		return false
+	}
+	r.current++
+	return true
+}
+
+// Set moves to snapshot i
+func (r *Railnet) Set(i int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i >= 0 && i < len(r.snapshots) {
+		r.current = i
+	}
+}
+
+// Play moves to the next snapshot every interval until the last one is reached
+func (r *Railnet) Play(interval time.Duration) {
+	go func() {
+		for {
+			time.Sleep(interval)
+			if !r.Next() {
+				return
+			}
+		}
+	}()
+}
+
+func (r *Railnet) serve(body func(Snapshot) []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		snapshot := r.snapshots[r.current]
+		r.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body(snapshot))
+	}
+}
+
+// Server is a railnet portal listening on a random local port
+type Server struct {
+	*httptest.Server
+	*Railnet
+}
+
+func NewServer(snapshots []Snapshot) *Server {
+	railnet := NewRailnet(snapshots)
+	return &Server{
+		Server:  httptest.NewServer(railnet),
+		Railnet: railnet,
+	}
+}
+
+// Provider returns an oebb.Provider talking to the server
+func (s *Server) Provider() *oebb.Provider {
+	p := oebb.NewOEBBProvider()
+	p.BaseURL = s.URL
+	p.Resolver = Resolver{}
+	p.SSIDs = nil
+	return p
+}
@@ -0,0 +1,77 @@
+package fakerailnet
+
+import (
+	"context"
+	"testing"
+	"time"
+	"trk/internal/lib/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProviderAgainstFakeRailnet(t *testing.T) {
+	snapshots, err := LoadSnapshots("testdata/salzburg-wien")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, snapshots, 3)
+	srv := NewServer(snapshots)
+	defer srv.Close()
+
+	p := srv.Provider()
+	p.PollInterval = time.Millisecond
+	ok, err := p.Probe()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	statusChan := make(chan types.Status)
+	done := make(chan error)
+	go func() {
+		done <- p.Run(ctx, statusChan)
+	}()
+	next := func(i int) types.Status {
+		srv.Set(i)
+		// the first status may have been fetched before switching
+		<-statusChan
+		return <-statusChan
+	}
+
+	status := next(0)
+	assert.Equal(t, "RJX66", status.Train.Id)
+	assert.Equal(t, "RJX 66", status.Train.DisplayName)
+	assert.Equal(t, "Railjet Xpress", status.Train.SeriesDisplay)
+	assert.InDelta(t, 198.4/3.6, status.Speed, 0.001)
+	assert.Equal(t, "Linz Hbf", status.NextStop.Name)
+	assert.Equal(t, time.Duration(0), status.Delay)
+	assert.InDelta(t, 47.997211, status.Location.Latitude, 0.000001)
+	assert.False(t, status.Timestamp.IsZero())
+
+	status = next(1)
+	assert.Equal(t, 6*time.Minute, status.Delay)
+	stops := types.Trip(p.GetStops())
+	if assert.Len(t, stops, 6) {
+		assert.True(t, stops[1].Passed)
+		assert.False(t, stops[2].Passed)
+		assert.True(t, stops[3].TrackChanged())
+		assert.Equal(t, "5", stops[3].Track)
+		assert.True(t, stops[0].ArrivalTime.IsZero())
+		assert.Equal(t, "2023-03-04T09:25:00+01:00", stops[0].DepartureTime.Format(time.RFC3339))
+	}
+
+	status = next(2)
+	assert.Equal(t, "St. Pölten Hbf", status.NextStop.Name)
+	stops = p.GetStops()
+	assert.True(t, stops[2].Passed)
+	assert.True(t, stops[4].Cancelled)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestRailnetStopsAtLastSnapshot(t *testing.T) {
+	railnet := NewRailnet(make([]Snapshot, 2))
+	assert.True(t, railnet.Next())
+	assert.False(t, railnet.Next())
+}
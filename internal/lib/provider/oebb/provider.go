@@ -0,0 +1,60 @@
+// Package oebb reads the onboard portal of Austrian ÖBB trains (Railjet, Nightjet) at railnet.oebb.at.
+//
+// The portal has two endpoints, /api/gps with the position and the speed and
+// /assets/modules/fis/combined.json with the train and its stations. The fixtures in
+// fakerailnet/testdata follow the structure of these responses.
+package oebb
+
+import (
+	"context"
+	"sync"
+	"trk/internal/lib/provider/portal"
+	"trk/internal/lib/types"
+)
+
+type Provider struct {
+	*portal.Client
+
+	mu       sync.Mutex
+	combined *Combined
+}
+
+func NewOEBBProvider() *Provider {
+	client := portal.NewClient("https://railnet.oebb.at")
+	// railnet.oebb.at only resolves to the portal inside the train
+	client.ValidAddress = portal.Onboard
+	client.SSIDs = []string{"OEBB"}
+	return &Provider{Client: client}
+}
+
+// Probe checks whether the portal resolves and returns a position
+func (p *Provider) Probe() (bool, error) {
+	return p.Client.Probe("/api/gps", &GPS{})
+}
+
+func (p *Provider) Run(ctx context.Context, statusChan chan types.Status) error {
+	return p.Poll(ctx, statusChan, p.fetch)
+}
+
+// fetch fetches the position and the trip and converts them into a status
+func (p *Provider) fetch(ctx context.Context) (types.Status, error) {
+	gps := GPS{}
+	now, err := p.Get(ctx, "/api/gps", &gps)
+	if err != nil {
+		return types.Status{}, err
+	}
+	combined := &Combined{}
+	if _, err = p.Get(ctx, "/assets/modules/fis/combined.json", combined); err != nil {
+		return types.Status{}, err
+	}
+	p.mu.Lock()
+	p.combined = combined
+	p.mu.Unlock()
+	return ConvertStatus(gps, combined, now), nil
+}
+
+func (p *Provider) GetStops() []types.Stop {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return ConvertStops(p.combined)
+}
@@ -0,0 +1,56 @@
+package oebb
+
+// https://railnet.oebb.at/api/gps
+type GPS struct {
+	Latitude  float64 `json:"Latitude"`
+	Longitude float64 `json:"Longitude"`
+	// Speed is in km/h
+	Speed float64 `json:"Speed"`
+}
+
+// https://railnet.oebb.at/assets/modules/fis/combined.json
+type Combined struct {
+	TrainType      string     `json:"trainType"`
+	LineNumber     string     `json:"lineNumber"`
+	TripNumber     string     `json:"tripNumber"`
+	Destination    Text       `json:"destination"`
+	CurrentStation StationRef `json:"currentStation"`
+	NextStation    StationRef `json:"nextStation"`
+	StationList    []Station  `json:"stationList"`
+}
+
+// Text is translated into several languages
+type Text struct {
+	De string `json:"de"`
+	En string `json:"en"`
+}
+
+type StationRef struct {
+	Id   string `json:"id"`
+	Name Text   `json:"name"`
+}
+
+type Station struct {
+	// Id is the UIC station number, the same as the evaNr of iceportal.de
+	Id          string `json:"id"`
+	Name        Text   `json:"name"`
+	Arrival     Time   `json:"arrival"`
+	Departure   Time   `json:"departure"`
+	Track       Track  `json:"track"`
+	Cancelled   bool   `json:"cancelled"`
+	Coordinates struct {
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
+	} `json:"coordinates"`
+}
+
+// Time is a timestamp in RFC 3339 format, empty if unknown
+type Time struct {
+	Scheduled string `json:"scheduled"`
+	Forecast  string `json:"forecast"`
+}
+
+type Track struct {
+	Scheduled string `json:"scheduled"`
+	Forecast  string `json:"forecast"`
+}
@@ -0,0 +1,204 @@
+// Package plugin runs providers written in any language as external processes.
+//
+// A plugin is an executable, trk calls it with a single argument:
+//
+//	<plugin> probe  // exit with 0 if the portal is available, with 1 if not
+//	<plugin> run    // write the status as JSON lines to stdout until killed
+//
+// While running, every line written to stdout is a JSON object with a type. The schema
+// mirrors types.Status and types.Stop, times are RFC 3339, missing times are unknown:
+//
+//	{"type": "trip", "stops": [{"id": "8000261", "name": "München Hbf",
+//	  "location": {"latitude": 48.14, "longitude": 11.56},
+//	  "scheduledArrivalTime": "2023-03-04T14:05:00+01:00", "arrivalTime": "2023-03-04T14:08:00+01:00",
+//	  "track": "12", "scheduledTrack": "11", "passed": false, "cancelled": false,
+//	  "delayReasons": [{"code": "38", "text": "Repairs on the track"}]}]}
+//
+//	{"type": "status", "status": {"train": {"id": "ICE123", "displayName": "ICE 123",
+//	  "lookupString": "ICE 123", "type": "ICE", "line": "123", "seriesDisplay": "ICE 4"},
+//	  "speed": 45.5, "timestamp": "2023-03-04T13:40:00+01:00", "delaySeconds": 180,
+//	  "location": {"latitude": 48.5, "longitude": 11.3}, "gpsLost": false,
+//	  "connectivity": {"currentState": "WEAK", "nextState": "HIGH", "nextInSeconds": 300}}}
+//
+// The speed is in m/s. A status without a nextStop gets the first stop of the last trip which
+// has not been passed. Lines which can't be read are logged and skipped, stderr goes to the log.
+//
+// If the plugin exits with 0, the portal is gone and probing starts again. If it crashes,
+// it is restarted. When trk quits, the plugin is killed.
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"trk/internal/lib/types"
+)
+
+// ErrPluginGone is returned by Run if the plugin exited or crashed too often
+var ErrPluginGone = errors.New("plugin is gone")
+
+type Provider struct {
+	// Command is the path of the executable, Args are passed before probe and run
+	Command string
+	Args    []string
+	// ProbeTimeout is how long probe may take, the plugin is killed afterwards
+	ProbeTimeout time.Duration
+	// MaxRestarts is how often the plugin is restarted after crashing in a row
+	MaxRestarts  int
+	RestartDelay time.Duration
+	// StableAfter resets the restarts, if the plugin ran at least this long before crashing
+	StableAfter time.Duration
+
+	mu   sync.Mutex
+	trip []types.Stop
+}
+
+func NewPluginProvider(command string) *Provider {
+	return &Provider{
+		Command:      command,
+		ProbeTimeout: 10 * time.Second,
+		MaxRestarts:  5,
+		RestartDelay: 2 * time.Second,
+		StableAfter:  time.Minute,
+	}
+}
+
+// Name is the file name of the plugin
+func (p *Provider) Name() string {
+	return filepath.Base(p.Command)
+}
+
+func (p *Provider) command(ctx context.Context, action string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, p.Command, append(append([]string{}, p.Args...), action)...)
+	cmd.Stderr = &logWriter{prefix: p.Name()}
+	cmd.SysProcAttr = sysProcAttr()
+	return cmd
+}
+
+// Probe runs the plugin with probe, it is available if it exits with 0
+func (p *Provider) Probe() (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.ProbeTimeout)
+	defer cancel()
+	err := p.command(ctx, "probe").Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return true, nil
+	case ctx.Err() != nil:
+		return false, fmt.Errorf("plugin %s: probe timed out", p.Name())
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		return false, nil
+	default:
+		return false, fmt.Errorf("plugin %s: probe: %w", p.Name(), err)
+	}
+}
+
+// Run runs the plugin with run and restarts it whenever it crashes
+func (p *Provider) Run(ctx context.Context, statusChan chan types.Status) error {
+	// the trip of a previous run may belong to another train
+	p.mu.Lock()
+	p.trip = nil
+	p.mu.Unlock()
+	restarts := 0
+	for {
+		started := time.Now()
+		err := p.run(ctx, statusChan)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil {
+			return fmt.Errorf("%w: %s exited", ErrPluginGone, p.Name())
+		}
+		if time.Since(started) >= p.StableAfter {
+			restarts = 0
+		}
+		if restarts >= p.MaxRestarts {
+			return fmt.Errorf("%w: %s crashed %d times in a row, last: %s", ErrPluginGone, p.Name(), restarts+1, err.Error())
+		}
+		restarts++
+		log.Printf("plugin %s crashed, restarting (%d/%d): %s", p.Name(), restarts, p.MaxRestarts, err.Error())
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(p.RestartDelay):
+		}
+	}
+}
+
+// run starts the plugin once and reads its lines until it exits, the plugin is killed when ctx is done
+func (p *Provider) run(ctx context.Context, statusChan chan types.Status) error {
+	cmd := p.command(ctx, "run")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := Line{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			log.Printf("plugin %s: invalid line: %s", p.Name(), err.Error())
+			continue
+		}
+		switch {
+		case line.Type == LineTrip:
+			p.mu.Lock()
+			p.trip = ConvertStops(line.Stops)
+			p.mu.Unlock()
+		case line.Type == LineStatus && line.Status != nil:
+			select {
+			case statusChan <- ConvertStatus(*line.Status, p.GetStops()):
+			case <-ctx.Done():
+				return cmd.Wait()
+			}
+		default:
+			log.Printf("plugin %s: unknown line %q", p.Name(), line.Type)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		// nobody reads stdout anymore, the plugin would block
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return err
+	}
+	return cmd.Wait()
+}
+
+// GetSSIDs returns nil, trk doesn't know the networks of a plugin
+func (p *Provider) GetSSIDs() []string {
+	return nil
+}
+
+// GetStops returns the trip last sent by the plugin
+func (p *Provider) GetStops() []types.Stop {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.trip
+}
+
+func (p *Provider) GetTrainInfo(s string) string {
+	return ""
+}
+
+// logWriter writes the stderr of a plugin to the log
+type logWriter struct {
+	prefix string
+}
+
+func (w *logWriter) Write(b []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(b), "\n"), "\n") {
+		log.Printf("plugin %s: %s", w.prefix, line)
+	}
+	return len(b), nil
+}
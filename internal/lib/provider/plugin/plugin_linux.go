@@ -0,0 +1,8 @@
+package plugin
+
+import "syscall"
+
+// sysProcAttr lets the kernel terminate the plugin if trk dies without killing it
+func sysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Pdeathsig: syscall.SIGTERM}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package plugin
+
+import "syscall"
+
+// sysProcAttr returns nil, only linux can terminate the plugin when trk dies
+func sysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
@@ -0,0 +1,161 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"trk/internal/lib/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHelperPlugin is the plugin run by the tests, TRK_TEST_PLUGIN selects its behaviour
+func TestHelperPlugin(t *testing.T) {
+	behaviour := os.Getenv("TRK_TEST_PLUGIN")
+	if behaviour == "" {
+		return
+	}
+	action := os.Args[len(os.Args)-1]
+	if action == "probe" {
+		if behaviour == "unavailable" {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if starts := os.Getenv("TRK_TEST_PLUGIN_STARTS"); starts != "" {
+		f, _ := os.OpenFile(starts, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		fmt.Fprintln(f, "started")
+		f.Close()
+	}
+	if behaviour != "no-trip" {
+		fmt.Println(`{"type": "trip", "stops": [` +
+			`{"id": "8000261", "name": "München Hbf", "scheduledArrivalTime": "2023-03-04T14:05:00+01:00", "passed": true},` +
+			`{"id": "8000183", "name": "Ingolstadt Hbf", "scheduledArrivalTime": "2023-03-04T14:40:00+01:00", ` +
+			`"arrivalTime": "2023-03-04T14:43:00+01:00", "track": "4", "scheduledTrack": "3"}]}`)
+	}
+	fmt.Println(`not json`)
+	fmt.Println(`{"type": "status", "status": {"train": {"id": "ICE123", "displayName": "ICE 123"}, ` +
+		`"speed": 45.5, "timestamp": "2023-03-04T14:20:00+01:00", "delaySeconds": 180, ` +
+		`"location": {"latitude": 48.5, "longitude": 11.3}, "connectivity": {"currentState": "WEAK"}}}`)
+	fmt.Fprintln(os.Stderr, "sent the status")
+	switch behaviour {
+	case "crash":
+		os.Exit(2)
+	case "hang":
+		time.Sleep(time.Hour)
+	}
+	os.Exit(0)
+}
+
+func helperProvider(t *testing.T, behaviour string) *Provider {
+	t.Setenv("TRK_TEST_PLUGIN", behaviour)
+	p := NewPluginProvider(os.Args[0])
+	p.Args = []string{"-test.run=^TestHelperPlugin$", "--"}
+	p.RestartDelay = time.Millisecond
+	return p
+}
+
+func TestProbe(t *testing.T) {
+	ok, err := helperProvider(t, "exit").Probe()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = helperProvider(t, "unavailable").Probe()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = NewPluginProvider(filepath.Join(t.TempDir(), "missing")).Probe()
+	assert.Error(t, err)
+	assert.False(t, ok)
+}
+
+func TestRun(t *testing.T) {
+	p := helperProvider(t, "exit")
+	statusChan := make(chan types.Status)
+	done := make(chan error)
+	go func() {
+		done <- p.Run(context.Background(), statusChan)
+	}()
+
+	status := <-statusChan
+	assert.Equal(t, "ICE 123", status.Train.DisplayName)
+	assert.Equal(t, 45.5, status.Speed)
+	assert.Equal(t, 3*time.Minute, status.Delay)
+	assert.Equal(t, types.Location{Latitude: 48.5, Longitude: 11.3}, status.Location)
+	assert.Equal(t, "WEAK", status.Connectivity.CurrentState)
+	// the next stop is taken from the trip
+	assert.Equal(t, "Ingolstadt Hbf", status.NextStop.Name)
+	assert.Equal(t, 3*time.Minute, status.NextStop.ArrivalDelay())
+	assert.True(t, status.NextStop.TrackChanged())
+
+	// exiting with 0 means the portal is gone
+	assert.ErrorIs(t, <-done, ErrPluginGone)
+	stops := p.GetStops()
+	if assert.Len(t, stops, 2) {
+		assert.True(t, stops[0].Passed)
+		assert.Equal(t, stops[0].ScheduledArrivalTime, stops[0].ArrivalTime)
+	}
+}
+
+func TestRunForgetsPreviousTrip(t *testing.T) {
+	p := helperProvider(t, "exit")
+	statusChan := make(chan types.Status, 1)
+	assert.ErrorIs(t, p.Run(context.Background(), statusChan), ErrPluginGone)
+	<-statusChan
+	assert.Len(t, p.GetStops(), 2)
+
+	t.Setenv("TRK_TEST_PLUGIN", "no-trip")
+	assert.ErrorIs(t, p.Run(context.Background(), statusChan), ErrPluginGone)
+	status := <-statusChan
+	assert.Equal(t, "", status.NextStop.Name)
+	assert.Empty(t, p.GetStops())
+}
+
+func TestRestartAfterCrash(t *testing.T) {
+	p := helperProvider(t, "crash")
+	starts := filepath.Join(t.TempDir(), "starts")
+	t.Setenv("TRK_TEST_PLUGIN_STARTS", starts)
+	p.MaxRestarts = 2
+	statusChan := make(chan types.Status)
+	done := make(chan error)
+	go func() {
+		done <- p.Run(context.Background(), statusChan)
+	}()
+
+	statuses := 0
+	for {
+		select {
+		case <-statusChan:
+			statuses++
+			continue
+		case err := <-done:
+			assert.ErrorIs(t, err, ErrPluginGone)
+		}
+		break
+	}
+	assert.Equal(t, 3, statuses)
+	content, _ := os.ReadFile(starts)
+	assert.Equal(t, 3, strings.Count(string(content), "started"))
+}
+
+func TestKillOnQuit(t *testing.T) {
+	p := helperProvider(t, "hang")
+	ctx, cancel := context.WithCancel(context.Background())
+	statusChan := make(chan types.Status)
+	done := make(chan error)
+	go func() {
+		done <- p.Run(ctx, statusChan)
+	}()
+	<-statusChan
+	cancel()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the plugin has not been killed")
+	}
+}
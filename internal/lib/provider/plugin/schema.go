@@ -0,0 +1,162 @@
+package plugin
+
+import (
+	"time"
+	"trk/internal/lib/types"
+)
+
+// Types of the lines written by a plugin
+const (
+	LineStatus = "status"
+	LineTrip   = "trip"
+)
+
+// Line is one line written by the plugin to stdout, Status is set for LineStatus, Stops for LineTrip
+type Line struct {
+	Type   string  `json:"type"`
+	Status *Status `json:"status,omitempty"`
+	Stops  []Stop  `json:"stops,omitempty"`
+}
+
+type Train struct {
+	Id            string `json:"id"`
+	DisplayName   string `json:"displayName"`
+	LookupString  string `json:"lookupString"`
+	Type          string `json:"type"`
+	Line          string `json:"line"`
+	Series        string `json:"series"`
+	SeriesDisplay string `json:"seriesDisplay"`
+	Operator      string `json:"operator"`
+}
+
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type DelayReason struct {
+	Code string `json:"code"`
+	Text string `json:"text"`
+}
+
+// Stop mirrors types.Stop, missing times are unknown
+type Stop struct {
+	Id                     string        `json:"id"`
+	Name                   string        `json:"name"`
+	Location               Location      `json:"location"`
+	ArrivalTime            time.Time     `json:"arrivalTime"`
+	ScheduledArrivalTime   time.Time     `json:"scheduledArrivalTime"`
+	DepartureTime          time.Time     `json:"departureTime"`
+	ScheduledDepartureTime time.Time     `json:"scheduledDepartureTime"`
+	Passed                 bool          `json:"passed"`
+	Cancelled              bool          `json:"cancelled"`
+	Track                  string        `json:"track"`
+	ScheduledTrack         string        `json:"scheduledTrack"`
+	DelayReasons           []DelayReason `json:"delayReasons"`
+}
+
+type Connectivity struct {
+	CurrentState string `json:"currentState"`
+	NextState    string `json:"nextState"`
+	// NextInSeconds is the time until NextState is reached
+	NextInSeconds int64 `json:"nextInSeconds"`
+}
+
+// Status mirrors types.Status. Without a NextStop, the first stop of the trip which has not been passed is used.
+type Status struct {
+	Train Train `json:"train"`
+	// Speed is in m/s
+	Speed     float64   `json:"speed"`
+	Timestamp time.Time `json:"timestamp"`
+	// DelaySeconds is the current delay
+	DelaySeconds int64        `json:"delaySeconds"`
+	Location     Location     `json:"location"`
+	NextStop     *Stop        `json:"nextStop,omitempty"`
+	GPSLost      bool         `json:"gpsLost"`
+	Connectivity Connectivity `json:"connectivity"`
+}
+
+// ConvertTrain maps a train of a plugin to types.Train
+func ConvertTrain(t Train) types.Train {
+	return types.Train{
+		Id:            t.Id,
+		DisplayName:   t.DisplayName,
+		LookupString:  t.LookupString,
+		Type:          t.Type,
+		Line:          t.Line,
+		Series:        t.Series,
+		SeriesDisplay: t.SeriesDisplay,
+		Operator:      t.Operator,
+	}
+}
+
+// ConvertLocation maps a location of a plugin to types.Location
+func ConvertLocation(l Location) types.Location {
+	return types.Location{
+		Latitude:  l.Latitude,
+		Longitude: l.Longitude,
+	}
+}
+
+// ConvertStop maps a stop of a plugin to types.Stop
+func ConvertStop(s Stop) types.Stop {
+	stop := types.Stop{
+		Id:                     s.Id,
+		Name:                   s.Name,
+		Location:               ConvertLocation(s.Location),
+		ArrivalTime:            s.ArrivalTime,
+		ScheduledArrivalTime:   s.ScheduledArrivalTime,
+		DepartureTime:          s.DepartureTime,
+		ScheduledDepartureTime: s.ScheduledDepartureTime,
+		Passed:                 s.Passed,
+		Cancelled:              s.Cancelled,
+		Track:                  s.Track,
+		ScheduledTrack:         s.ScheduledTrack,
+	}
+	for _, reason := range s.DelayReasons {
+		stop.DelayReasons = append(stop.DelayReasons, types.DelayReason{Code: reason.Code, Text: reason.Text})
+	}
+	// like the portals, the actual times fall back to the scheduled ones
+	if stop.ArrivalTime.IsZero() {
+		stop.ArrivalTime = stop.ScheduledArrivalTime
+	}
+	if stop.DepartureTime.IsZero() {
+		stop.DepartureTime = stop.ScheduledDepartureTime
+	}
+	return stop
+}
+
+// ConvertStops maps the trip of a plugin to types.Stop
+func ConvertStops(stops []Stop) []types.Stop {
+	result := make([]types.Stop, 0, len(stops))
+	for _, stop := range stops {
+		result = append(result, ConvertStop(stop))
+	}
+	return result
+}
+
+// ConvertStatus maps a status of a plugin to types.Status, trip are the stops last sent by the plugin
+func ConvertStatus(s Status, trip []types.Stop) types.Status {
+	status := types.Status{
+		Train:     ConvertTrain(s.Train),
+		Speed:     s.Speed,
+		Timestamp: s.Timestamp,
+		Delay:     time.Duration(s.DelaySeconds) * time.Second,
+		Location:  ConvertLocation(s.Location),
+		GPSLost:   s.GPSLost,
+		Connectivity: types.Connectivity{
+			CurrentState: s.Connectivity.CurrentState,
+			NextState:    s.Connectivity.NextState,
+			NextIn:       time.Duration(s.Connectivity.NextInSeconds) * time.Second,
+		},
+	}
+	if status.Timestamp.IsZero() {
+		status.Timestamp = time.Now()
+	}
+	if s.NextStop != nil {
+		status.NextStop = ConvertStop(*s.NextStop)
+	} else if next := types.Trip(trip).GetNextStop(); next != nil {
+		status.NextStop = *next
+	}
+	return status
+}
@@ -0,0 +1,229 @@
+// Package portal fetches JSON from the onboard portals which are polled over plain HTTP,
+// it is shared by the ice, oebb, cd and sncf providers.
+package portal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	pathpkg "path"
+	"strings"
+	"time"
+	"trk/internal/lib/netbind"
+	"trk/internal/lib/types"
+)
+
+// ErrPortalGone is returned by Poll when the portal can not be reached anymore, e.g. after leaving the train
+var ErrPortalGone = errors.New("portal not reachable anymore")
+
+// ResponseRecorder receives every raw response fetched from the portal
+type ResponseRecorder interface {
+	Record(name string, requested time.Time, resp *http.Response, body []byte) error
+}
+
+// Resolver looks up the addresses of the portal, *net.Resolver satisfies it
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// Onboard accepts the private and loopback addresses a portal resolves to inside the train
+func Onboard(ip net.IP) bool {
+	return ip.IsPrivate() || ip.IsLoopback()
+}
+
+// ParseTime parses a time in RFC 3339 format, it returns the zero time if s is empty or invalid
+func ParseTime(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// Client fetches from the portal at BaseURL. The providers embed it, so its fields are
+// configured on the provider.
+type Client struct {
+	BaseURL        string
+	Resolver       Resolver
+	ResolveTimeout time.Duration
+	// ValidAddress checks the resolved addresses of the portal, nil accepts any address
+	ValidAddress func(net.IP) bool
+	PollInterval time.Duration
+	MaxFailures  int
+	// SSIDs are the networks of the portal, nil lets the portal be probed in any network
+	SSIDs []string
+	// Recorder receives the responses if set, named after the last element of their path
+	Recorder ResponseRecorder
+
+	binding netbind.Binding
+	client  *http.Client
+}
+
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL:        baseURL,
+		Resolver:       net.DefaultResolver,
+		ResolveTimeout: 5 * time.Second,
+		PollInterval:   5 * time.Second,
+		MaxFailures:    6,
+		client: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+}
+
+// SetBaseURL points the client at another portal, e.g. a fake one, which is probed
+// in any network and may resolve to any address
+func (c *Client) SetBaseURL(baseURL string) {
+	c.BaseURL = baseURL
+	c.ValidAddress = nil
+	c.SSIDs = nil
+}
+
+func (c *Client) GetSSIDs() []string {
+	return c.SSIDs
+}
+
+func (c *Client) GetTrainInfo(s string) string {
+	return ""
+}
+
+// Probe checks whether the portal resolves to a valid address and answers path with JSON
+// decoded into v. Outside the train the host does not resolve or the api does not answer.
+func (c *Client) Probe(path string, v any) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.ResolveTimeout)
+	defer cancel()
+	ok, err := c.resolves(ctx)
+	if err != nil || !ok {
+		return false, err
+	}
+	if _, err = c.Get(ctx, path, v); err != nil {
+		log.Printf("%s not available: %s\n", c.BaseURL, err.Error())
+		return false, nil
+	}
+	return true, nil
+}
+
+// resolves returns true if the host of BaseURL resolves to an address accepted by ValidAddress
+func (c *Client) resolves(ctx context.Context) (bool, error) {
+	baseURL, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return false, err
+	}
+	addrs, err := c.Resolver.LookupIPAddr(ctx, baseURL.Hostname())
+	if err != nil {
+		return false, nil
+	}
+	if c.ValidAddress == nil {
+		return true, nil
+	}
+	for _, addr := range addrs {
+		if c.ValidAddress(addr.IP) {
+			return true, nil
+		}
+	}
+	log.Printf("%s resolves to no portal address, are you in the correct wifi? %v\n", baseURL.Hostname(), addrs)
+	return false, nil
+}
+
+// Get fetches path below BaseURL and decodes the JSON response into v. The returned time
+// is the portal's time from the Date header, the local time if it sent none.
+func (c *Client) Get(ctx context.Context, path string, v any) (time.Time, error) {
+	requestURL := c.BaseURL + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	requested := time.Now()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if c.Recorder != nil {
+		name := strings.TrimSuffix(pathpkg.Base(path), pathpkg.Ext(path))
+		if err := c.Recorder.Record(name, requested, resp, body); err != nil {
+			log.Printf("error recording %s: %s", name, err.Error())
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("%s: %d", requestURL, resp.StatusCode)
+	}
+	if err = json.Unmarshal(body, v); err != nil {
+		return time.Time{}, err
+	}
+	now, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		now = time.Now()
+	}
+	return now, nil
+}
+
+// Poll calls fetch every PollInterval and sends the status to statusChan until ctx is cancelled.
+// It returns ErrPortalGone after MaxFailures failed fetches in a row, or after the first one if
+// the portal does not resolve to a valid address anymore.
+func (c *Client) Poll(ctx context.Context, statusChan chan types.Status, fetch func(ctx context.Context) (types.Status, error)) error {
+	ticker := time.NewTicker(c.PollInterval)
+	defer ticker.Stop()
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		status, err := fetch(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			failures++
+			log.Printf("error fetching %s (%d/%d): %s", c.BaseURL, failures, c.MaxFailures, err.Error())
+			if c.ValidAddress != nil {
+				if ok, _ := c.resolves(ctx); !ok {
+					return fmt.Errorf("%w: %s does not resolve to the portal anymore", ErrPortalGone, c.BaseURL)
+				}
+			}
+			if failures >= c.MaxFailures {
+				return fmt.Errorf("%w: %s, %d consecutive failures, last: %s", ErrPortalGone, c.BaseURL, failures, err.Error())
+			}
+			continue
+		}
+		failures = 0
+
+		select {
+		case statusChan <- status:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Bind resolves the portal and fetches from it through binding, the zero binding restores the defaults
+func (c *Client) Bind(binding netbind.Binding) {
+	if binding.Interface == c.binding.Interface && strings.Join(binding.DNS, ",") == strings.Join(c.binding.DNS, ",") {
+		return
+	}
+	c.binding = binding
+	c.Resolver = binding.Resolver()
+	c.client.CloseIdleConnections()
+	if binding.IsZero() {
+		c.client.Transport = nil
+		return
+	}
+	c.client.Transport = binding.Transport()
+}
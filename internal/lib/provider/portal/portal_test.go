@@ -0,0 +1,178 @@
+package portal
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"trk/internal/lib/netbind"
+	"trk/internal/lib/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type staticResolver []net.IP
+
+func (r staticResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	addrs := make([]net.IPAddr, 0, len(r))
+	for _, ip := range r {
+		addrs = append(addrs, net.IPAddr{IP: ip})
+	}
+	return addrs, nil
+}
+
+type position struct {
+	Speed float64 `json:"speed"`
+}
+
+func newPortal(status int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/gps" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Date", "Sat, 04 Mar 2023 10:00:00 GMT")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"speed": 72}`))
+	}))
+}
+
+func TestProbe(t *testing.T) {
+	srv := newPortal(http.StatusOK)
+	defer srv.Close()
+	c := NewClient(srv.URL)
+	c.Resolver = staticResolver{net.IPv4(127, 0, 0, 1)}
+	c.ValidAddress = Onboard
+
+	v := position{}
+	ok, err := c.Probe("/api/gps", &v)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 72.0, v.Speed)
+
+	// outside the train the api is missing
+	ok, err = c.Probe("/api/missing", &v)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// or the host resolves to the public website
+	c.Resolver = staticResolver{net.IPv4(193, 170, 106, 10)}
+	ok, err = c.Probe("/api/gps", &v)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	c.ValidAddress = nil
+	ok, _ = c.Probe("/api/gps", &v)
+	assert.True(t, ok)
+}
+
+func TestGet(t *testing.T) {
+	srv := newPortal(http.StatusOK)
+	defer srv.Close()
+	c := NewClient(srv.URL)
+
+	v := position{}
+	now, err := c.Get(context.Background(), "/api/gps", &v)
+	assert.NoError(t, err)
+	assert.True(t, now.Equal(time.Date(2023, 3, 4, 10, 0, 0, 0, time.UTC)))
+
+	srv = newPortal(http.StatusServiceUnavailable)
+	defer srv.Close()
+	c.BaseURL = srv.URL
+	_, err = c.Get(context.Background(), "/api/gps", &v)
+	assert.Error(t, err)
+}
+
+func TestPoll(t *testing.T) {
+	c := NewClient("http://portal.invalid")
+	c.PollInterval = time.Millisecond
+	c.MaxFailures = 3
+
+	fetches := 0
+	statusChan := make(chan types.Status, 10)
+	err := c.Poll(context.Background(), statusChan, func(ctx context.Context) (types.Status, error) {
+		fetches++
+		// a success in between resets the failures
+		if fetches == 2 {
+			return types.Status{Speed: 20}, nil
+		}
+		return types.Status{}, errors.New("timeout")
+	})
+	assert.ErrorIs(t, err, ErrPortalGone)
+	assert.Equal(t, 5, fetches)
+	assert.Len(t, statusChan, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = c.Poll(ctx, statusChan, func(ctx context.Context) (types.Status, error) {
+		return types.Status{}, nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestPollStopsOutsideTheTrain(t *testing.T) {
+	c := NewClient("http://portal.invalid")
+	c.PollInterval = time.Millisecond
+	c.Resolver = staticResolver{net.IPv4(193, 170, 106, 10)}
+	c.ValidAddress = Onboard
+
+	fetches := 0
+	err := c.Poll(context.Background(), make(chan types.Status, 1), func(ctx context.Context) (types.Status, error) {
+		fetches++
+		return types.Status{}, errors.New("timeout")
+	})
+	assert.ErrorIs(t, err, ErrPortalGone)
+	assert.Equal(t, 1, fetches)
+}
+
+type recorded struct {
+	names  []string
+	status []int
+}
+
+func (r *recorded) Record(name string, requested time.Time, resp *http.Response, body []byte) error {
+	r.names = append(r.names, name)
+	r.status = append(r.status, resp.StatusCode)
+	return nil
+}
+
+func TestGetRecordsResponses(t *testing.T) {
+	srv := newPortal(http.StatusServiceUnavailable)
+	defer srv.Close()
+	c := NewClient(srv.URL)
+	recorder := &recorded{}
+	c.Recorder = recorder
+
+	_, err := c.Get(context.Background(), "/api/gps", &position{})
+	assert.Error(t, err)
+	_, _ = c.Get(context.Background(), "/assets/modules/fis/combined.json", &position{})
+	assert.Equal(t, []string{"gps", "combined"}, recorder.names)
+	assert.Equal(t, []int{http.StatusServiceUnavailable, http.StatusNotFound}, recorder.status)
+}
+
+func TestSetBaseURL(t *testing.T) {
+	c := NewClient("https://railnet.oebb.at")
+	c.ValidAddress = Onboard
+	c.SSIDs = []string{"OEBB"}
+	c.SetBaseURL("http://localhost:8080")
+	assert.Equal(t, "http://localhost:8080", c.BaseURL)
+	assert.Nil(t, c.ValidAddress)
+	assert.Nil(t, c.GetSSIDs())
+}
+
+func TestBind(t *testing.T) {
+	c := NewClient("https://railnet.oebb.at")
+	c.Bind(netbind.Binding{Interface: "wlp3s0", DNS: []string{"10.10.0.1"}})
+	transport := c.client.Transport
+	assert.NotNil(t, transport)
+	assert.NotSame(t, net.DefaultResolver, c.Resolver)
+
+	c.Bind(netbind.Binding{Interface: "wlp3s0", DNS: []string{"10.10.0.1"}})
+	assert.Same(t, transport, c.client.Transport)
+
+	c.Bind(netbind.Binding{})
+	assert.Nil(t, c.client.Transport)
+	assert.Same(t, net.DefaultResolver, c.Resolver)
+}
@@ -0,0 +1,29 @@
+// Package portaltest serves fixtures in place of an onboard portal for the tests of the providers
+// built on portal.Client.
+package portaltest
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+)
+
+// Resolver resolves every host to the loopback address, which portal.Onboard accepts
+type Resolver struct{}
+
+func (Resolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return []net.IPAddr{{IP: net.IPv4(127, 0, 0, 1)}}, nil
+}
+
+// NewServer serves the files, keyed by the path they are served at. Other paths are not found.
+func NewServer(files map[string]string) *httptest.Server {
+	mux := http.NewServeMux()
+	for path, file := range files {
+		file := file
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			http.ServeFile(w, r, file)
+		})
+	}
+	return httptest.NewServer(mux)
+}
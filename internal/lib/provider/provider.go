@@ -1,10 +1,63 @@
 package provider
 
-import "trk/internal/lib/types"
+import (
+	"context"
+	"trk/internal/lib/netbind"
+	"trk/internal/lib/types"
+)
 
 type Provider interface {
 	Probe() (bool, error)
-	Run(statusChan chan types.Status)
+	// Run polls the portal and sends the status to statusChan until ctx is cancelled
+	// or the portal is not reachable anymore. The returned error tells which one happened.
+	Run(ctx context.Context, statusChan chan types.Status) error
 	GetStops() []types.Stop
 	GetTrainInfo(string) string
+	// GetSSIDs returns the WiFi networks the provider belongs to, nil if it does not depend on the WiFi
+	GetSSIDs() []string
+}
+
+// Bindable is implemented by providers which can reach their portal through a specific
+// network interface and DNS servers, e.g. while a VPN is connected
+type Bindable interface {
+	Bind(binding netbind.Binding)
+}
+
+// Fallback is implemented by providers which only know the position, e.g. from a GPS receiver.
+// They are given up as soon as the portal of the train becomes available.
+type Fallback interface {
+	Fallback()
+}
+
+// ForSSIDs returns the providers worth probing while connected to the networks ssids,
+// providers without SSIDs are always included. If ssids is nil, the networks are unknown
+// and all providers are returned.
+func ForSSIDs(providers []Provider, ssids []string) []Provider {
+	if ssids == nil {
+		return providers
+	}
+	var matching []Provider
+	for _, p := range providers {
+		own := p.GetSSIDs()
+		if own == nil || containsAny(own, ssids) {
+			matching = append(matching, p)
+		}
+	}
+	return matching
+}
+
+func containsAny(values, wanted []string) bool {
+	for _, v := range values {
+		for _, w := range wanted {
+			if v == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ConnectionProvider is implemented by providers which know the onward connections at the stops of the trip
+type ConnectionProvider interface {
+	GetConnections(ctx context.Context, stopId string) ([]types.Connection, error)
 }
@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"trk/internal/lib/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ssidProvider struct {
+	name  string
+	ssids []string
+}
+
+func (p ssidProvider) Probe() (bool, error)                               { return false, nil }
+func (p ssidProvider) Run(ctx context.Context, _ chan types.Status) error { return nil }
+func (p ssidProvider) GetStops() []types.Stop                             { return nil }
+func (p ssidProvider) GetTrainInfo(string) string                         { return "" }
+func (p ssidProvider) GetSSIDs() []string                                 { return p.ssids }
+
+func names(providers []Provider) []string {
+	var result []string
+	for _, p := range providers {
+		result = append(result, p.(ssidProvider).name)
+	}
+	return result
+}
+
+func TestForSSIDs(t *testing.T) {
+	providers := []Provider{
+		ssidProvider{name: "ice", ssids: []string{"WIFIonICE", "WIFI@DB"}},
+		ssidProvider{name: "oebb", ssids: []string{"OEBB"}},
+		ssidProvider{name: "gps"},
+	}
+	assert.Equal(t, []string{"ice", "gps"}, names(ForSSIDs(providers, []string{"WIFI@DB"})))
+	assert.Equal(t, []string{"oebb", "gps"}, names(ForSSIDs(providers, []string{"Home", "OEBB"})))
+	assert.Equal(t, []string{"gps"}, names(ForSSIDs(providers, []string{})))
+	assert.Equal(t, []string{"ice", "oebb", "gps"}, names(ForSSIDs(providers, nil)))
+}
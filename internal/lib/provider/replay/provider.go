@@ -0,0 +1,237 @@
+// Package replay plays back recorded iceportal.de sessions.
+//
+// A recording is a directory of raw response bodies named after the time
+// they were fetched (unix milliseconds) and the endpoint:
+//
+//	1677321667090_status.json  // /api1/rs/status
+//	1677321666090_trip.json    // /api1/rs/tripInfo/trip
+//
+// This is the layout written by recorder.ResponseRecorder, responses
+// recorded with a status code other than 200 are skipped.
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"trk/internal/lib/provider/ice"
+	"trk/internal/lib/recorder"
+	"trk/internal/lib/types"
+)
+
+// ErrEndOfRecording is returned by Run after the last status has been played back
+var ErrEndOfRecording = errors.New("end of recording")
+
+type entryKind string
+
+const (
+	kindStatus entryKind = "status"
+	kindTrip   entryKind = "trip"
+)
+
+type entry struct {
+	time time.Time
+	kind entryKind
+	path string
+}
+
+type Provider struct {
+	Dir string
+
+	mu      sync.Mutex
+	speed   float64
+	seek    time.Time
+	offset  time.Duration
+	entries []entry
+	trip    *ice.Trip
+}
+
+func NewReplayProvider(dir string) *Provider {
+	return &Provider{
+		Dir:   dir,
+		speed: 1,
+	}
+}
+
+// SetSpeed changes the playback speed, 1 is real time, 10 is ten times as fast.
+// A speed <= 0 plays the recording without any pauses.
+func (p *Provider) SetSpeed(speed float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.speed = speed
+}
+
+// Seek skips all status responses recorded before t. Trip responses before t are still
+// applied, so the first status sent has the trip that was known at that time.
+func (p *Provider) Seek(t time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.seek = t
+	p.offset = 0
+}
+
+// SeekOffset skips all status responses recorded in the first d of the recording, like Seek
+func (p *Provider) SeekOffset(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.seek = time.Time{}
+	p.offset = d
+}
+
+// Probe loads the recording and succeeds if it contains at least one status response
+func (p *Provider) Probe() (bool, error) {
+	entries, err := readEntries(p.Dir)
+	if err != nil {
+		return false, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries = entries
+	for _, e := range entries {
+		if e.kind == kindStatus {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (p *Provider) Run(ctx context.Context, statusChan chan types.Status) error {
+	p.mu.Lock()
+	entries := p.entries
+	if p.offset != 0 && len(entries) > 0 {
+		p.seek = entries[0].time.Add(p.offset)
+		p.offset = 0
+	}
+	p.mu.Unlock()
+
+	var last time.Time
+	for _, e := range entries {
+		if e.kind == kindTrip {
+			trip := &ice.Trip{}
+			if err := readJSON(e.path, trip); err != nil {
+				return err
+			}
+			p.mu.Lock()
+			p.trip = trip
+			p.mu.Unlock()
+			continue
+		}
+
+		p.mu.Lock()
+		speed, seek := p.speed, p.seek
+		trip := p.trip
+		p.mu.Unlock()
+		if e.time.Before(seek) {
+			continue
+		}
+
+		if !last.IsZero() && speed > 0 {
+			select {
+			case <-time.After(time.Duration(float64(e.time.Sub(last)) / speed)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		last = e.time
+
+		status := ice.Status{}
+		if err := readJSON(e.path, &status); err != nil {
+			return err
+		}
+		select {
+		case statusChan <- ice.ConvertStatus(status, trip):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return ErrEndOfRecording
+}
+
+func (p *Provider) GetStops() []types.Stop {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return ice.ConvertStops(p.trip)
+}
+
+// GetSSIDs returns nil, a recording can be played back anywhere
+func (p *Provider) GetSSIDs() []string {
+	return nil
+}
+
+func (p *Provider) GetTrainInfo(s string) string {
+	return ""
+}
+
+// readEntries lists all recorded responses in dir, sorted by the time they were recorded
+func readEntries(dir string) ([]entry, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]entry, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		e, ok := parseName(file.Name())
+		if !ok {
+			continue
+		}
+		e.path = filepath.Join(dir, file.Name())
+		if !successful(strings.TrimSuffix(e.path, ".json") + ".meta.json") {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].time.Before(entries[j].time)
+	})
+	return entries, nil
+}
+
+func parseName(name string) (entry, bool) {
+	base := strings.TrimSuffix(name, ".json")
+	if base == name {
+		return entry{}, false
+	}
+	millis, kind, found := strings.Cut(base, "_")
+	if !found || (entryKind(kind) != kindStatus && entryKind(kind) != kindTrip) {
+		return entry{}, false
+	}
+	ms, err := strconv.ParseInt(millis, 10, 64)
+	if err != nil {
+		return entry{}, false
+	}
+	return entry{time: time.UnixMilli(ms), kind: entryKind(kind)}, true
+}
+
+// successful checks the meta file written by the recorder.
+// Responses without a meta file are assumed to be successful.
+func successful(metaPath string) bool {
+	meta := recorder.ResponseMeta{}
+	if err := readJSON(metaPath, &meta); err != nil {
+		return true
+	}
+	return meta.StatusCode == http.StatusOK
+}
+
+func readJSON(path string, v any) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err = json.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
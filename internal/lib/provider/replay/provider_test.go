@@ -0,0 +1,68 @@
+package replay
+
+import (
+	"context"
+	"testing"
+	"time"
+	"trk/internal/lib/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func play(t *testing.T, p *Provider) []types.Status {
+	ok, err := p.Probe()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	statusChan := make(chan types.Status)
+	done := make(chan error)
+	go func() {
+		done <- p.Run(context.Background(), statusChan)
+	}()
+	var received []types.Status
+	for {
+		select {
+		case status := <-statusChan:
+			received = append(received, status)
+		case err := <-done:
+			assert.ErrorIs(t, err, ErrEndOfRecording)
+			return received
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	p := NewReplayProvider("testdata/session")
+	p.SetSpeed(0)
+	received := play(t, p)
+
+	if assert.Len(t, received, 3) {
+		assert.Equal(t, "ICE9048", received[0].Train.Id)
+		assert.Equal(t, "ICE 4", received[0].Train.SeriesDisplay)
+		assert.Equal(t, time.UnixMilli(1677321672090).Truncate(time.Second), received[1].Timestamp)
+		assert.NotEqual(t, received[1].NextStop.Id, received[2].NextStop.Id)
+	}
+	assert.Len(t, p.GetStops(), 13)
+}
+
+func TestReplaySeek(t *testing.T) {
+	p := NewReplayProvider("testdata/session")
+	p.SetSpeed(0)
+	p.Seek(time.UnixMilli(1677321677090))
+	received := play(t, p)
+
+	if assert.Len(t, received, 1) {
+		assert.Equal(t, time.UnixMilli(1677321677090).Truncate(time.Second), received[0].Timestamp)
+	}
+}
+
+func TestReplaySeekOffset(t *testing.T) {
+	p := NewReplayProvider("testdata/session")
+	p.SetSpeed(0)
+	p.SeekOffset(5 * time.Second)
+	received := play(t, p)
+
+	if assert.Len(t, received, 2) {
+		assert.Equal(t, time.UnixMilli(1677321672090).Truncate(time.Second), received[0].Timestamp)
+	}
+}
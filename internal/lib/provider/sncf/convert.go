@@ -0,0 +1,126 @@
+package sncf
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // the portal reports French local times, so Europe/Paris must be known on every system
+	"trk/internal/lib/provider/portal"
+	"trk/internal/lib/types"
+)
+
+// localLayout is the format of the portal's times, they carry no offset
+const localLayout = "2006-01-02T15:04:05"
+
+// paris is the timezone of the portal's times
+var paris = mustLoadLocation("Europe/Paris")
+
+func mustLoadLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return location
+}
+
+// ConvertTrain maps the train of the details to types.Train, e.g. TGV INOUI 6611
+func ConvertTrain(details Details) types.Train {
+	trainType := "TGV"
+	seriesDisplay := "TGV " + details.Carrier
+	if strings.EqualFold(details.Carrier, "OUIGO") {
+		trainType = "OUIGO"
+		seriesDisplay = "OUIGO"
+	}
+	name := fmt.Sprintf("%s %s", trainType, details.Number)
+	return types.Train{
+		Id:            trainType + details.Number,
+		DisplayName:   name,
+		LookupString:  name,
+		Type:          trainType,
+		Line:          details.Number,
+		SeriesDisplay: strings.TrimSpace(seriesDisplay),
+	}
+}
+
+// ConvertStatus maps the position and the details to a types.Status, now is used
+// to tell the passed stops if the position has no timestamp
+func ConvertStatus(gps GPS, details *Details, now time.Time) types.Status {
+	if gps.Timestamp > 0 {
+		now = time.Unix(gps.Timestamp, 0)
+	}
+	result := types.Status{
+		Speed:     gps.Speed, // the portal reports m/s
+		Timestamp: now,
+		Location: types.Location{
+			Latitude:  gps.Latitude,
+			Longitude: gps.Longitude,
+		},
+		GPSLost: !gps.Success || gps.Fix <= 0,
+	}
+	if details == nil {
+		return result
+	}
+	result.Train = ConvertTrain(*details)
+	if next := types.Trip(ConvertStops(details, now)).GetNextStop(); next != nil {
+		result.NextStop = *next
+		result.Delay = next.Delay()
+		if result.Delay < 0 {
+			result.Delay = 0
+		}
+	}
+	return result
+}
+
+// ConvertStops maps the stops of the details to types.Stop. The portal doesn't tell which stops
+// have been passed, a stop counts as passed once its actual departure is before now.
+func ConvertStops(details *Details, now time.Time) []types.Stop {
+	if details == nil {
+		return nil
+	}
+	stops := make([]types.Stop, 0, len(details.Stops))
+	for i, s := range details.Stops {
+		stop := types.Stop{
+			Id:   s.Code,
+			Name: s.Label,
+			Location: types.Location{
+				Latitude:  s.Coordinates.Latitude,
+				Longitude: s.Coordinates.Longitude,
+			},
+			Track:     s.Platform,
+			Cancelled: s.IsRemoved,
+		}
+		scheduled := parseTime(s.TheoricDate)
+		actual := parseTime(s.RealDate)
+		if actual.IsZero() {
+			actual = scheduled
+		}
+		if i > 0 {
+			stop.ScheduledArrivalTime = scheduled
+			stop.ArrivalTime = actual
+		}
+		if i < len(details.Stops)-1 && !scheduled.IsZero() {
+			dwell := time.Duration(s.Duration) * time.Minute
+			stop.ScheduledDepartureTime = scheduled.Add(dwell)
+			stop.DepartureTime = actual.Add(dwell)
+		}
+		left := stop.DepartureTime
+		if left.IsZero() {
+			left = stop.ArrivalTime
+		}
+		stop.Passed = !left.IsZero() && left.Before(now)
+		stops = append(stops, stop)
+	}
+	return stops
+}
+
+// parseTime reads the portal's local times as Europe/Paris, times with an offset are taken as they are
+func parseTime(s string) time.Time {
+	if t := portal.ParseTime(s); !t.IsZero() || s == "" {
+		return t
+	}
+	t, err := time.ParseInLocation(localLayout, s, paris)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,62 @@
+package sncf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTime(t *testing.T) {
+	// summer and winter time in Paris
+	assert.True(t, parseTime("2023-07-14T11:04:00").Equal(time.Date(2023, 7, 14, 9, 4, 0, 0, time.UTC)))
+	assert.True(t, parseTime("2023-01-14T11:04:00").Equal(time.Date(2023, 1, 14, 10, 4, 0, 0, time.UTC)))
+	// an offset sent by the portal wins
+	assert.True(t, parseTime("2023-07-14T11:04:00Z").Equal(time.Date(2023, 7, 14, 11, 4, 0, 0, time.UTC)))
+	assert.True(t, parseTime("").IsZero())
+	assert.True(t, parseTime("14/07/2023").IsZero())
+}
+
+func TestConvertStops(t *testing.T) {
+	details := &Details{
+		Number: "6611",
+		Stops: []Stop{
+			{Code: "87686006", Label: "Paris Gare de Lyon", TheoricDate: "2023-07-14T09:07:00"},
+			{Code: "87723197", Label: "Lyon Part-Dieu", TheoricDate: "2023-07-14T11:04:00", RealDate: "2023-07-14T11:09:00", Duration: 3},
+			{Code: "87751008", Label: "Marseille Saint-Charles", TheoricDate: "2023-07-14T12:47:00"},
+		},
+	}
+	// 11:10 in Paris, the train is still at Lyon Part-Dieu
+	now := time.Date(2023, 7, 14, 9, 10, 0, 0, time.UTC)
+	stops := ConvertStops(details, now)
+
+	assert.True(t, stops[0].ArrivalTime.IsZero())
+	assert.True(t, stops[0].Passed)
+	assert.Equal(t, 5*time.Minute, stops[1].ArrivalDelay())
+	assert.Equal(t, 3*time.Minute, stops[1].DwellTime())
+	assert.True(t, stops[1].DepartureTime.Equal(time.Date(2023, 7, 14, 9, 12, 0, 0, time.UTC)))
+	assert.False(t, stops[1].Passed)
+	assert.True(t, stops[2].DepartureTime.IsZero())
+	assert.Equal(t, time.Duration(0), stops[2].Delay())
+}
+
+func TestConvertStatusWithoutFix(t *testing.T) {
+	now := time.Date(2023, 7, 14, 9, 10, 0, 0, time.UTC)
+	status := ConvertStatus(GPS{Success: false}, nil, now)
+	assert.True(t, status.GPSLost)
+	assert.Equal(t, now, status.Timestamp)
+}
+
+func TestConvertStatusEarlyTrain(t *testing.T) {
+	details := &Details{
+		Number: "6611",
+		Stops: []Stop{
+			{Code: "87686006", Label: "Paris Gare de Lyon", TheoricDate: "2023-07-14T09:07:00"},
+			{Code: "87723197", Label: "Lyon Part-Dieu", TheoricDate: "2023-07-14T11:04:00", RealDate: "2023-07-14T11:02:00", Duration: 3},
+		},
+	}
+	status := ConvertStatus(GPS{Success: true, Fix: 1}, details, time.Date(2023, 7, 14, 8, 50, 0, 0, time.UTC))
+	assert.Equal(t, "Lyon Part-Dieu", status.NextStop.Name)
+	assert.Equal(t, -2*time.Minute, status.NextStop.Delay())
+	assert.Equal(t, time.Duration(0), status.Delay)
+}
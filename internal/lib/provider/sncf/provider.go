@@ -0,0 +1,68 @@
+// Package sncf reads the onboard portal of French TGV INOUI and OUIGO trains at wifi.sncf.
+//
+// The portal has two endpoints, /router/api/train/gps with the position and the speed
+// and /router/api/train/details with the train and its stops. The times of the stops are
+// French local times without an offset. The fixtures in testdata follow the structure of
+// these responses.
+package sncf
+
+import (
+	"context"
+	"sync"
+	"time"
+	"trk/internal/lib/provider/portal"
+	"trk/internal/lib/types"
+)
+
+type Provider struct {
+	*portal.Client
+
+	mu      sync.Mutex
+	details *Details
+	// updated is the time of the last position, it tells the passed stops
+	updated time.Time
+}
+
+func NewSNCFProvider() *Provider {
+	client := portal.NewClient("https://wifi.sncf")
+	client.SSIDs = []string{"_SNCF_WIFI_INOUI", "_SNCF_WIFI_INTERCITES", "OUIFI"}
+	return &Provider{Client: client}
+}
+
+// Probe checks whether the portal resolves and returns a position. Outside the train
+// wifi.sncf does not resolve.
+func (p *Provider) Probe() (bool, error) {
+	return p.Client.Probe("/router/api/train/gps", &GPS{})
+}
+
+func (p *Provider) Run(ctx context.Context, statusChan chan types.Status) error {
+	return p.Poll(ctx, statusChan, p.fetch)
+}
+
+// fetch fetches the position and the details and converts them into a status
+func (p *Provider) fetch(ctx context.Context) (types.Status, error) {
+	gps := GPS{}
+	now, err := p.Get(ctx, "/router/api/train/gps", &gps)
+	if err != nil {
+		return types.Status{}, err
+	}
+	details := &Details{}
+	if _, err = p.Get(ctx, "/router/api/train/details", details); err != nil {
+		return types.Status{}, err
+	}
+	status := ConvertStatus(gps, details, now)
+	p.mu.Lock()
+	p.details = details
+	p.updated = status.Timestamp
+	p.mu.Unlock()
+	return status, nil
+}
+
+func (p *Provider) GetStops() []types.Stop {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.updated.IsZero() {
+		return ConvertStops(p.details, time.Now())
+	}
+	return ConvertStops(p.details, p.updated)
+}
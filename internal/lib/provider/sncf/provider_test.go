@@ -0,0 +1,57 @@
+package sncf
+
+import (
+	"context"
+	"testing"
+	"time"
+	"trk/internal/lib/provider/portal/portaltest"
+	"trk/internal/lib/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider(t *testing.T) {
+	srv := portaltest.NewServer(map[string]string{
+		"/router/api/train/gps":     "testdata/gps.json",
+		"/router/api/train/details": "testdata/details.json",
+	})
+	defer srv.Close()
+	p := NewSNCFProvider()
+	p.BaseURL = srv.URL
+	p.Resolver = portaltest.Resolver{}
+	p.PollInterval = time.Millisecond
+
+	ok, err := p.Probe()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	statusChan := make(chan types.Status)
+	done := make(chan error)
+	go func() {
+		done <- p.Run(ctx, statusChan)
+	}()
+
+	status := <-statusChan
+	assert.Equal(t, "TGV6611", status.Train.Id)
+	assert.Equal(t, "TGV 6611", status.Train.DisplayName)
+	assert.Equal(t, "TGV INOUI", status.Train.SeriesDisplay)
+	assert.Equal(t, 83.6, status.Speed)
+	assert.False(t, status.GPSLost)
+	assert.True(t, status.Timestamp.Equal(time.Date(2023, 7, 14, 9, 30, 0, 0, time.UTC)))
+	assert.Equal(t, "Avignon TGV", status.NextStop.Name)
+	assert.Equal(t, 6*time.Minute, status.Delay)
+
+	stops := p.GetStops()
+	if assert.Len(t, stops, 5) {
+		assert.True(t, stops[0].Passed)
+		assert.True(t, stops[1].Passed)
+		assert.False(t, stops[2].Passed)
+		assert.True(t, stops[3].Cancelled)
+		assert.Equal(t, "B", stops[2].Track)
+	}
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
@@ -0,0 +1,46 @@
+package sncf
+
+// https://wifi.sncf/router/api/train/gps
+type GPS struct {
+	Success bool `json:"success"`
+	// Fix is the GPS fix, 0 or less without a position
+	Fix int `json:"fix"`
+	// Timestamp is the time of the fix in unix seconds
+	Timestamp int64   `json:"timestamp"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude"`
+	// Speed is in m/s
+	Speed   float64 `json:"speed"`
+	Heading float64 `json:"heading"`
+}
+
+// https://wifi.sncf/router/api/train/details
+type Details struct {
+	Number string `json:"number"`
+	// Carrier is the brand of the train, e.g. INOUI or OUIGO
+	Carrier string `json:"carrier"`
+	Stops   []Stop `json:"stops"`
+}
+
+type Stop struct {
+	// Code is the UIC station number
+	Code  string `json:"code"`
+	Label string `json:"label"`
+	// TheoricDate and RealDate are the scheduled and the forecast arrival,
+	// the departure at the first stop. They are French local times without an offset.
+	TheoricDate string `json:"theoricDate"`
+	RealDate    string `json:"realDate"`
+	// Duration is the dwell time in minutes, 0 at the first and the last stop
+	Duration    int         `json:"duration"`
+	IsDelayed   bool        `json:"isDelayed"`
+	Delay       int         `json:"delay"`
+	IsRemoved   bool        `json:"isRemoved"`
+	Platform    string      `json:"platform"`
+	Coordinates Coordinates `json:"coordinates"`
+}
+
+type Coordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
@@ -1,4 +1,258 @@
 package recorder
 
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+	"trk/internal/lib/types"
+)
+
+const (
+	gpxNamespace        = "http://www.topografix.com/GPX/1/1"
+	trackPointNamespace = "http://www.garmin.com/xmlschemas/TrackPointExtension/v2"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+type gpxDocument struct {
+	XMLName   xml.Name      `xml:"gpx"`
+	Version   string        `xml:"version,attr"`
+	Creator   string        `xml:"creator,attr"`
+	Xmlns     string        `xml:"xmlns,attr"`
+	XmlnsTPX  string        `xml:"xmlns:gpxtpx,attr"`
+	Metadata  gpxMetadata   `xml:"metadata"`
+	Waypoints []gpxWaypoint `xml:"wpt"`
+	Track     gpxTrack      `xml:"trk"`
+}
+
+type gpxMetadata struct {
+	Name string `xml:"name"`
+	Time string `xml:"time"`
+}
+
+type gpxWaypoint struct {
+	Latitude  float64 `xml:"lat,attr"`
+	Longitude float64 `xml:"lon,attr"`
+	Time      string  `xml:"time,omitempty"`
+	Name      string  `xml:"name"`
+	Desc      string  `xml:"desc,omitempty"`
+	Type      string  `xml:"type"`
+}
+
+type gpxTrack struct {
+	Name    string       `xml:"name"`
+	Type    string       `xml:"type,omitempty"`
+	Segment []gpxTrackPt `xml:"trkseg>trkpt"`
+}
+
+type gpxTrackPt struct {
+	Latitude   float64          `xml:"lat,attr"`
+	Longitude  float64          `xml:"lon,attr"`
+	Time       string           `xml:"time"`
+	Extensions gpxTPXExtensions `xml:"extensions"`
+}
+
+type gpxTPXExtensions struct {
+	Speed float64 `xml:"gpxtpx:TrackPointExtension>gpxtpx:speed"`
+}
+
+// GPXRecorder writes the status stream as GPX 1.1 tracks, one file per train.
+// The file is rewritten atomically on every flush, so a crash or lost connection
+// only loses the points since the last flush.
 type GPXRecorder struct {
+	Dir           string
+	FlushInterval time.Duration
+
+	mu        sync.Mutex
+	train     types.Train
+	path      string
+	started   time.Time
+	points    []gpxTrackPt
+	waypoints []gpxWaypoint
+	lastStop  types.Stop
+	lastTime  time.Time
+	lastFlush time.Time
+	dirty     bool
+}
+
+func NewGPXRecorder(dir string) *GPXRecorder {
+	return &GPXRecorder{
+		Dir:           dir,
+		FlushInterval: 30 * time.Second,
+	}
+}
+
+// Record adds a status to the current track. A new file is started whenever the train changes.
+func (r *GPXRecorder) Record(status types.Status) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if status.Train.Id != r.train.Id {
+		r.finish()
+		if err := r.flush(); err != nil {
+			return err
+		}
+		r.start(status)
+	}
+
+	if r.lastStop.Id != "" && status.NextStop.Id != r.lastStop.Id {
+		// the previous next stop is behind us now
+		r.addWaypoint(r.lastStop)
+	}
+	r.lastStop = status.NextStop
+	if !status.Timestamp.IsZero() {
+		r.lastTime = status.Timestamp
+	}
+
+	if status.Location != (types.Location{}) && !status.Timestamp.IsZero() {
+		timestamp := formatTime(status.Timestamp)
+		if len(r.points) == 0 || r.points[len(r.points)-1].Time != timestamp {
+			r.points = append(r.points, gpxTrackPt{
+				Latitude:   status.Location.Latitude,
+				Longitude:  status.Location.Longitude,
+				Time:       timestamp,
+				Extensions: gpxTPXExtensions{Speed: status.Speed},
+			})
+			r.dirty = true
+		}
+	}
+
+	if time.Since(r.lastFlush) >= r.FlushInterval {
+		return r.flush()
+	}
+	return nil
+}
+
+// Flush writes the current track to disk
+func (r *GPXRecorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.flush()
+}
+
+// Close writes the pending points and ends the current track
+func (r *GPXRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.finish()
+	err := r.flush()
+	r.path = ""
+	r.train = types.Train{}
+	return err
+}
+
+// finish adds the waypoint of the last stop when the track ends, if the train arrived there.
+// The last stop of a trip usually never becomes a passed one.
+func (r *GPXRecorder) finish() {
+	stop := r.lastStop
+	r.lastStop = types.Stop{}
+	if stop.Id == "" || stop.ArrivalTime.IsZero() || r.lastTime.Before(stop.ArrivalTime) {
+		return
+	}
+	r.addWaypoint(stop)
+}
+
+func (r *GPXRecorder) start(status types.Status) {
+	r.train = status.Train
+	r.started = status.Timestamp
+	if r.started.IsZero() {
+		r.started = time.Now()
+	}
+	r.points = nil
+	r.waypoints = nil
+	r.lastStop = types.Stop{}
+	r.lastTime = time.Time{}
+	r.dirty = false
+	r.path = ""
+	if status.Train.Id == "" {
+		return
+	}
+	name := unsafeFileChars.ReplaceAllString(status.Train.DisplayName, "_")
+	if name == "" {
+		name = unsafeFileChars.ReplaceAllString(status.Train.Id, "_")
+	}
+	r.path = filepath.Join(r.Dir, fmt.Sprintf("%s_%s.gpx", r.started.Format("2006-01-02T150405"), name))
+}
+
+func (r *GPXRecorder) addWaypoint(stop types.Stop) {
+	if stop.Location == (types.Location{}) {
+		return
+	}
+	wpt := gpxWaypoint{
+		Latitude:  stop.Location.Latitude,
+		Longitude: stop.Location.Longitude,
+		Name:      stop.Name,
+		Desc:      fmt.Sprintf("EVA %s", stop.Id),
+		Type:      "Station",
+	}
+	if !stop.ArrivalTime.IsZero() {
+		wpt.Time = formatTime(stop.ArrivalTime)
+	}
+	r.waypoints = append(r.waypoints, wpt)
+	r.dirty = true
+}
+
+func (r *GPXRecorder) flush() error {
+	r.lastFlush = time.Now()
+	if r.path == "" || !r.dirty {
+		return nil
+	}
+	doc := gpxDocument{
+		Version:  "1.1",
+		Creator:  "trk",
+		Xmlns:    gpxNamespace,
+		XmlnsTPX: trackPointNamespace,
+		Metadata: gpxMetadata{
+			Name: r.train.DisplayName,
+			Time: formatTime(r.started),
+		},
+		Waypoints: r.waypoints,
+		Track: gpxTrack{
+			Name:    r.train.DisplayName,
+			Type:    r.train.Type,
+			Segment: r.points,
+		},
+	}
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = writeFileAtomic(r.path, append([]byte(xml.Header), data...)); err != nil {
+		return err
+	}
+	r.dirty = false
+	return nil
+}
+
+// writeFileAtomic writes into a temporary file next to path and renames it afterwards,
+// so path either contains the old or the new content, never a partial write.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".trk-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
 }
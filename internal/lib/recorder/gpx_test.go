@@ -0,0 +1,89 @@
+package recorder
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"trk/internal/lib/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGPXRecorder(t *testing.T) {
+	dir := t.TempDir()
+	r := NewGPXRecorder(dir)
+	start := time.Date(2023, 2, 11, 12, 0, 0, 0, time.UTC)
+	koeln := types.Stop{Id: "8000207", Name: "Köln Hbf", Location: types.Location{Latitude: 50.94, Longitude: 6.95}, ArrivalTime: start.Add(time.Minute)}
+	bonn := types.Stop{Id: "8000044", Name: "Bonn Hbf", Location: types.Location{Latitude: 50.73, Longitude: 7.09}, ArrivalTime: start.Add(2 * time.Minute)}
+
+	for i, stop := range []types.Stop{koeln, koeln, bonn} {
+		assert.NoError(t, r.Record(types.Status{
+			Train:     types.Train{Id: "9001", DisplayName: "ICE 123"},
+			Speed:     40,
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+			Location:  types.Location{Latitude: 50.9 - float64(i)*0.1, Longitude: 7},
+			NextStop:  stop,
+		}))
+	}
+	assert.NoError(t, r.Record(types.Status{
+		Train:     types.Train{Id: "9002", DisplayName: "IC 2012"},
+		Timestamp: start.Add(time.Hour),
+		Location:  types.Location{Latitude: 50.7, Longitude: 7.1},
+	}))
+	assert.NoError(t, r.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.gpx"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	data, err := os.ReadFile(filepath.Join(dir, "2023-02-11T120000_ICE_123.gpx"))
+	assert.NoError(t, err)
+	var doc gpxDocument
+	assert.NoError(t, xml.Unmarshal(data, &doc))
+	assert.Len(t, doc.Track.Segment, 3)
+	assert.Contains(t, string(data), "<gpxtpx:speed>40</gpxtpx:speed>")
+	// the final stop is written when the train changes, it has been reached by then
+	if assert.Len(t, doc.Waypoints, 2) {
+		assert.Equal(t, "Köln Hbf", doc.Waypoints[0].Name)
+		assert.Equal(t, "2023-02-11T12:01:00Z", doc.Waypoints[0].Time)
+		assert.Equal(t, "Bonn Hbf", doc.Waypoints[1].Name)
+	}
+}
+
+func TestGPXRecorderFinalStopOnClose(t *testing.T) {
+	dir := t.TempDir()
+	r := NewGPXRecorder(dir)
+	start := time.Date(2023, 2, 11, 12, 0, 0, 0, time.UTC)
+	bonn := types.Stop{Id: "8000044", Name: "Bonn Hbf", Location: types.Location{Latitude: 50.73, Longitude: 7.09}, ArrivalTime: start.Add(time.Minute)}
+	koblenz := types.Stop{Id: "8000206", Name: "Koblenz Hbf", Location: types.Location{Latitude: 50.35, Longitude: 7.59}, ArrivalTime: start.Add(4 * time.Hour)}
+	record := func(at time.Duration, stop types.Stop) {
+		assert.NoError(t, r.Record(types.Status{
+			Train:     types.Train{Id: "9001", DisplayName: "ICE 123"},
+			Timestamp: start.Add(at),
+			Location:  types.Location{Latitude: 50.8, Longitude: 7},
+			NextStop:  stop,
+		}))
+	}
+	record(0, bonn)
+	record(2*time.Minute, bonn)
+	assert.NoError(t, r.Close())
+
+	data, err := os.ReadFile(filepath.Join(dir, "2023-02-11T120000_ICE_123.gpx"))
+	assert.NoError(t, err)
+	var doc gpxDocument
+	assert.NoError(t, xml.Unmarshal(data, &doc))
+	if assert.Len(t, doc.Waypoints, 1) {
+		assert.Equal(t, "Bonn Hbf", doc.Waypoints[0].Name)
+	}
+
+	// a stop which has not been reached yet is left out
+	record(3*time.Hour, koblenz)
+	assert.NoError(t, r.Close())
+	data, err = os.ReadFile(filepath.Join(dir, "2023-02-11T150000_ICE_123.gpx"))
+	assert.NoError(t, err)
+	doc = gpxDocument{}
+	assert.NoError(t, xml.Unmarshal(data, &doc))
+	assert.Empty(t, doc.Waypoints)
+}
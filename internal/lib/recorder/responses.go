@@ -0,0 +1,114 @@
+package recorder
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const metaSuffix = ".meta.json"
+
+// ResponseMeta is stored next to every recorded response body
+type ResponseMeta struct {
+	URL        string      `json:"url"`
+	Requested  time.Time   `json:"requested"`
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+}
+
+// ResponseRecorder stores raw portal responses in Dir, named like the replay provider
+// expects them (`<unix millis>_<name>.json`), together with a `.meta.json` file
+// containing the request time, status code and headers.
+// Once more than MaxEntries responses are stored, the oldest ones are removed.
+type ResponseRecorder struct {
+	Dir        string
+	MaxEntries int
+
+	mu      sync.Mutex
+	entries []string
+}
+
+// NewResponseRecorder creates dir if necessary and picks up the responses already stored in it,
+// so the rotation also covers previous runs
+func NewResponseRecorder(dir string) (*ResponseRecorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	r := &ResponseRecorder{
+		Dir:        dir,
+		MaxEntries: 20000,
+	}
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, ".json") || strings.HasSuffix(name, metaSuffix) {
+			continue
+		}
+		r.entries = append(r.entries, strings.TrimSuffix(name, ".json"))
+	}
+	sort.Slice(r.entries, func(i, j int) bool {
+		return entryMillis(r.entries[i]) < entryMillis(r.entries[j])
+	})
+	return r, nil
+}
+
+// Record stores the body of resp, which has been requested at `requested`
+func (r *ResponseRecorder) Record(name string, requested time.Time, resp *http.Response, body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	base := fmt.Sprintf("%d_%s", requested.UnixMilli(), name)
+	meta := ResponseMeta{
+		Requested:  requested,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+	}
+	if resp.Request != nil && resp.Request.URL != nil {
+		meta.URL = resp.Request.URL.String()
+	}
+	metaData, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	// the meta file is written first, so the replay never sees a body without its status code
+	if err = writeFileAtomic(filepath.Join(r.Dir, base+metaSuffix), metaData); err != nil {
+		return err
+	}
+	if err = writeFileAtomic(filepath.Join(r.Dir, base+".json"), body); err != nil {
+		return err
+	}
+	r.entries = append(r.entries, base)
+	return r.rotate()
+}
+
+func (r *ResponseRecorder) rotate() error {
+	if r.MaxEntries <= 0 || len(r.entries) <= r.MaxEntries {
+		return nil
+	}
+	outdated := r.entries[:len(r.entries)-r.MaxEntries]
+	r.entries = r.entries[len(outdated):]
+	for _, base := range outdated {
+		for _, name := range []string{base + ".json", base + metaSuffix} {
+			if err := os.Remove(filepath.Join(r.Dir, name)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func entryMillis(base string) int64 {
+	millis, _, _ := strings.Cut(base, "_")
+	ms, _ := strconv.ParseInt(millis, 10, 64)
+	return ms
+}
@@ -0,0 +1,40 @@
+package recorder
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseRecorderRotation(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewResponseRecorder(dir)
+	assert.NoError(t, err)
+	r.MaxEntries = 2
+
+	start := time.UnixMilli(1677321667090)
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"application/json"}}}
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, r.Record("status", start.Add(time.Duration(i)*time.Second), resp, []byte(`{}`)))
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 4)
+	_, err = os.Stat(filepath.Join(dir, "1677321667090_status.json"))
+	assert.True(t, os.IsNotExist(err))
+
+	// a new recorder continues the rotation of the previous one
+	r, err = NewResponseRecorder(dir)
+	assert.NoError(t, err)
+	r.MaxEntries = 2
+	assert.NoError(t, r.Record("trip", start.Add(time.Minute), resp, []byte(`{}`)))
+	_, err = os.Stat(filepath.Join(dir, "1677321668090_status.json"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, "1677321669090_status.meta.json"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,224 @@
+package tracker
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"trk/internal/lib/alarm"
+	"trk/internal/lib/events"
+	"trk/internal/lib/helper"
+	"trk/internal/lib/transfer"
+	"trk/internal/lib/types"
+)
+
+// Notifier shows a notification to the user, e.g. on the desktop or in the terminal
+type Notifier interface {
+	Notify(summary, body string, t helper.NotificationType, data any)
+}
+
+// Tracker turns the trip events into notifications
+type Tracker struct {
+	notifier Notifier
+}
+
+func NewTracker(notifier Notifier) *Tracker {
+	return &Tracker{
+		notifier: notifier,
+	}
+}
+
+// Run handles all events until the channel is closed
+func (t *Tracker) Run(eventChan <-chan events.Event) {
+	for event := range eventChan {
+		t.Handle(event)
+	}
+}
+
+// NotifyArrival reminds the user to get ready to leave the train
+func (t *Tracker) NotifyArrival(reminder alarm.Reminder) {
+	stop := reminder.Stop
+	when := "now"
+	if reminder.Remaining >= time.Minute {
+		when = fmt.Sprintf("in %d min", int(reminder.Remaining.Minutes()))
+	}
+	body := fmt.Sprintf("Arriving at <b>%s</b> %s (%s)", stop.Name, when,
+		helper.FormatTimetable(stop.ArrivalTime, stop.ScheduledArrivalTime))
+	if stop.Track != "" {
+		body += fmt.Sprintf(" on track <b>%s</b>", stop.Track)
+	}
+	t.notifier.Notify("Destination", body, helper.AlarmNotification, reminder)
+}
+
+// NotifyMissedConnection warns that connection can't be reached anymore with our arrival at stop
+func (t *Tracker) NotifyMissedConnection(stop types.Stop, connection types.Connection) {
+	t.notifier.Notify(
+		"Connection",
+		fmt.Sprintf("<b>%s</b> can't be reached anymore, arriving at <b>%s</b> %s",
+			helper.FormatConnection(connection),
+			stop.Name,
+			helper.FormatTimetable(stop.ArrivalTime, stop.ScheduledArrivalTime),
+		),
+		helper.ConnectionNotification,
+		stop.Location,
+	)
+}
+
+// NotifyTransfer tells the user how much time is left for changing to the pinned connection,
+// and which connection to take instead once it is missed
+func (t *Tracker) NotifyTransfer(risk transfer.Risk) {
+	connection := helper.FormatConnection(risk.Connection)
+	var body string
+	switch {
+	case risk.Missed && risk.Alternative != nil:
+		body = fmt.Sprintf("<b>%s</b> will be missed — next: <b>%s</b>", connection, helper.FormatConnection(*risk.Alternative))
+	case risk.Missed:
+		body = fmt.Sprintf("<b>%s</b> will be missed — no alternative found", connection)
+	default:
+		body = fmt.Sprintf("Transfer to <b>%s</b> now %d min", connection, int(risk.Buffer.Minutes()))
+		if risk.Tight {
+			body += " — tight"
+		}
+	}
+	t.notifier.Notify("Transfer", body, helper.ConnectionNotification, risk.Stop.Location)
+}
+
+// connectivityRank orders the connectivity states from offline to good, unknown states are -1
+var connectivityRank = map[string]int{
+	types.ConnectivityNone:     0,
+	types.ConnectivityUnstable: 1,
+	types.ConnectivityWeak:     2,
+	types.ConnectivityHigh:     3,
+}
+
+// connectivityForecast returns e.g. "Internet will be weak in 2 min" before a dead zone
+// and "Internet weak for ~8 min" inside of it, empty if the forecast is not worth a notification
+func connectivityForecast(c types.Connectivity) string {
+	current, ok := connectivityRank[c.CurrentState]
+	if !ok {
+		return ""
+	}
+	next, ok := connectivityRank[c.NextState]
+	if !ok || c.NextIn <= 0 {
+		return ""
+	}
+	in := helper.FormatMinutes(c.NextIn)
+	switch {
+	case next < current && c.NextState == types.ConnectivityNone:
+		return fmt.Sprintf("No internet in %s", in)
+	case next < current:
+		return fmt.Sprintf("Internet will be %s in %s", helper.ConnectivityName(c.NextState), in)
+	case next > current && c.CurrentState == types.ConnectivityNone:
+		return fmt.Sprintf("No internet for ~%s", in)
+	case next > current:
+		return fmt.Sprintf("Internet %s for ~%s", helper.ConnectivityName(c.CurrentState), in)
+	}
+	return ""
+}
+
+// delayNotified returns true if a change to delay is worth a notification
+func delayNotified(delay time.Duration) bool {
+	return delay > time.Minute*2
+}
+
+// delayText returns e.g. "+12 min — Reparatur an der Strecke"
+func delayText(delay time.Duration, reasons []types.DelayReason) string {
+	texts := make([]string, 0, len(reasons))
+	for _, reason := range reasons {
+		texts = append(texts, reason.Text)
+	}
+	if len(texts) == 0 {
+		return helper.FormatDelay(delay)
+	}
+	if delay == 0 {
+		return strings.Join(texts, ", ")
+	}
+	return fmt.Sprintf("%s — %s", helper.FormatDelay(delay), strings.Join(texts, ", "))
+}
+
+// notifyPlatformChange tells the user the new track of stop. The old track is taken from previous
+// if it is set, otherwise the scheduled track is used.
+func (t *Tracker) notifyPlatformChange(stop types.Stop, previous *types.Stop, destination bool) {
+	oldTrack := stop.ScheduledTrack
+	if previous != nil && previous.Track != "" {
+		oldTrack = previous.Track
+	}
+	if stop.Track == "" || stop.Track == oldTrack {
+		return
+	}
+	notificationType := helper.PlatformNotification
+	if destination {
+		notificationType = helper.DestinationPlatformNotification
+	}
+	t.notifier.Notify(
+		"Platform change",
+		fmt.Sprintf("<b>%s</b> now track <b>%s</b> instead of %s", stop.Name, stop.Track, oldTrack),
+		notificationType,
+		stop.Location,
+	)
+}
+
+func (t *Tracker) Handle(event events.Event) {
+	status := event.Status
+	switch event.Type {
+	case events.TrainChanged:
+		if status.Train.DisplayName == "" {
+			// the provider doesn't know the train, e.g. gpsd
+			return
+		}
+		train := status.Train
+		t.notifier.Notify(
+			"New Train", fmt.Sprintf("Welcome to <b>%s</b> (It's %s %s)",
+				status.Train.DisplayName,
+				helper.GetIndefiniteArticle(status.Train.SeriesDisplay),
+				status.Train.SeriesDisplay,
+			), helper.TrainNotification, &train)
+	case events.NextStopChanged:
+		t.notifier.Notify(
+			"Next Stop",
+			fmt.Sprintf(
+				"<b>%s</b> at %s",
+				event.Stop.Name,
+				helper.FormatTimetable(event.Stop.ArrivalTime, event.Stop.ScheduledArrivalTime),
+			),
+			helper.StationNotification,
+			event.Stop.Location,
+		)
+		if event.Stop.TrackChanged() {
+			t.notifyPlatformChange(*event.Stop, nil, event.Destination)
+		}
+	case events.TrackChanged:
+		// a changed track of a new next stop is already announced with NextStopChanged
+		if event.Destination || (event.Previous != nil && event.Stop.Id == status.NextStop.Id) {
+			t.notifyPlatformChange(*event.Stop, event.Previous, event.Destination)
+		}
+	case events.DestinationChanged:
+		// remind of a platform change which was announced before the stop became the destination
+		if event.Stop != nil && event.Stop.TrackChanged() {
+			t.notifyPlatformChange(*event.Stop, nil, true)
+		}
+	case events.DelayChanged:
+		if delayNotified(status.Delay) {
+			t.notifier.Notify(
+				"Delay",
+				"Current Delay is "+delayText(status.Delay, status.NextStop.DelayReasons),
+				helper.DelayNotification,
+				nil,
+			)
+		}
+	case events.ConnectivityChanging:
+		if text := connectivityForecast(status.Connectivity); text != "" {
+			t.notifier.Notify("Internet", text, helper.ConnectivityNotification, nil)
+		}
+	case events.DelayReasonAdded:
+		if status.Delay != event.PreviousDelay && delayNotified(status.Delay) {
+			// the delay changed in the same snapshot, its notification already lists the reasons
+			return
+		}
+		t.notifier.Notify(
+			"Delay reason",
+			delayText(status.Delay, event.Reasons),
+			helper.DelayNotification,
+			nil,
+		)
+	}
+}
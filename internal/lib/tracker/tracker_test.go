@@ -0,0 +1,208 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+	"trk/internal/lib/events"
+	"trk/internal/lib/helper"
+	"trk/internal/lib/transfer"
+	"trk/internal/lib/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type notification struct {
+	Summary string
+	Body    string
+	Type    helper.NotificationType
+}
+
+type recordingNotifier struct {
+	notifications []notification
+}
+
+func (r *recordingNotifier) Notify(summary, body string, t helper.NotificationType, data any) {
+	r.notifications = append(r.notifications, notification{Summary: summary, Body: body, Type: t})
+}
+
+// visible returns the notifications left on the desktop, a notification replaces the previous one of its type
+func (r *recordingNotifier) visible() []notification {
+	var visible []notification
+Outer:
+	for i, n := range r.notifications {
+		for _, later := range r.notifications[i+1:] {
+			if later.Type == n.Type {
+				continue Outer
+			}
+		}
+		visible = append(visible, n)
+	}
+	return visible
+}
+
+func TestPlatformChange(t *testing.T) {
+	notifier := &recordingNotifier{}
+	tracker := NewTracker(notifier)
+	koeln := types.Stop{Id: "8000207", Name: "Köln Hbf", Track: "6", ScheduledTrack: "6"}
+	bonn := types.Stop{Id: "8000044", Name: "Bonn Hbf", Track: "2", ScheduledTrack: "2"}
+	status := types.Status{NextStop: koeln}
+
+	engine := events.NewEngine()
+	engine.SetDestination(bonn.Id)
+	for _, event := range engine.Feed(status, types.Trip{koeln, bonn}) {
+		tracker.Handle(event)
+	}
+	notifier.notifications = nil
+
+	koeln.Track = "4"
+	bonn.Track = "3"
+	status.NextStop = koeln
+	for _, event := range engine.Feed(status, types.Trip{koeln, bonn}) {
+		tracker.Handle(event)
+	}
+	assert.Equal(t, []notification{
+		{"Platform change", "<b>Köln Hbf</b> now track <b>4</b> instead of 6", helper.PlatformNotification},
+		{"Platform change", "<b>Bonn Hbf</b> now track <b>3</b> instead of 2", helper.DestinationPlatformNotification},
+	}, notifier.visible())
+}
+
+func TestPlatformChangeOfNewDestination(t *testing.T) {
+	notifier := &recordingNotifier{}
+	tracker := NewTracker(notifier)
+	koeln := types.Stop{Id: "8000207", Name: "Köln Hbf", Track: "4", ScheduledTrack: "6"}
+	bonn := types.Stop{Id: "8000044", Name: "Bonn Hbf", Track: "3", ScheduledTrack: "2"}
+
+	engine := events.NewEngine()
+	eventChan, unsubscribe := engine.Subscribe()
+	engine.Feed(types.Status{NextStop: koeln}, types.Trip{koeln, bonn})
+	engine.SetDestination(bonn.Id)
+	unsubscribe()
+	tracker.Run(eventChan)
+
+	assert.Equal(t, []notification{
+		{"Next Stop", "<b>Köln Hbf</b> at ", helper.StationNotification},
+		{"Platform change", "<b>Köln Hbf</b> now track <b>4</b> instead of 6", helper.PlatformNotification},
+		{"Platform change", "<b>Bonn Hbf</b> now track <b>3</b> instead of 2", helper.DestinationPlatformNotification},
+	}, notifier.visible())
+}
+
+func TestDelayReasons(t *testing.T) {
+	notifier := &recordingNotifier{}
+	tracker := NewTracker(notifier)
+	repair := types.DelayReason{Code: "38", Text: "Reparatur an der Strecke"}
+	koeln := types.Stop{Id: "8000207", Name: "Köln Hbf"}
+	status := types.Status{NextStop: koeln}
+
+	engine := events.NewEngine()
+	engine.Feed(status, types.Trip{koeln})
+
+	koeln.DelayReasons = []types.DelayReason{repair}
+	status.NextStop = koeln
+	status.Delay = 12 * time.Minute
+	for _, event := range engine.Feed(status, types.Trip{koeln}) {
+		tracker.Handle(event)
+	}
+	// the delay notification already contains the new reason
+	assert.Equal(t, []notification{
+		{"Delay", "Current Delay is +12 min — Reparatur an der Strecke", helper.DelayNotification},
+	}, notifier.notifications)
+
+	// known reasons are not announced again
+	notifier.notifications = nil
+	for _, event := range engine.Feed(status, types.Trip{koeln}) {
+		tracker.Handle(event)
+	}
+	assert.Empty(t, notifier.notifications)
+
+	// a new reason without a change of the delay
+	signal := types.DelayReason{Code: "40", Text: "Defektes Stellwerk"}
+	koeln.DelayReasons = append(koeln.DelayReasons, signal)
+	status.NextStop = koeln
+	for _, event := range engine.Feed(status, types.Trip{koeln}) {
+		tracker.Handle(event)
+	}
+	assert.Equal(t, []notification{
+		{"Delay reason", "+12 min — Defektes Stellwerk", helper.DelayNotification},
+	}, notifier.notifications)
+}
+
+func TestMissedConnection(t *testing.T) {
+	notifier := &recordingNotifier{}
+	tracker := NewTracker(notifier)
+	arrival := time.Date(2023, 2, 25, 13, 52, 0, 0, time.Local)
+	muenchen := types.Stop{Id: "8000261", Name: "München Hbf", ArrivalTime: arrival.Add(7 * time.Minute), ScheduledArrivalTime: arrival}
+	connection := types.Connection{
+		Train:                  types.Train{DisplayName: "RE 1"},
+		Destination:            "Salzburg Hbf",
+		ScheduledDepartureTime: arrival.Add(6 * time.Minute),
+		DepartureTime:          arrival.Add(6 * time.Minute),
+		Track:                  "26",
+	}
+	tracker.NotifyMissedConnection(muenchen, connection)
+	assert.Equal(t, []notification{
+		{"Connection", "<b>RE 1 → Salzburg Hbf 13:58 track 26</b> can't be reached anymore, arriving at <b>München Hbf</b> 13:59 (+7)", helper.ConnectionNotification},
+	}, notifier.notifications)
+}
+
+func TestTransfer(t *testing.T) {
+	notifier := &recordingNotifier{}
+	tracker := NewTracker(notifier)
+	departure := time.Date(2023, 2, 25, 15, 4, 0, 0, time.Local)
+	re5 := types.Connection{
+		Train:                  types.Train{DisplayName: "RE 5"},
+		Destination:            "Koblenz Hbf",
+		ScheduledDepartureTime: departure,
+		DepartureTime:          departure,
+	}
+	rb26 := re5
+	rb26.Train.DisplayName = "RB 26"
+	rb26.ScheduledDepartureTime = departure.Add(20 * time.Minute)
+	rb26.DepartureTime = rb26.ScheduledDepartureTime
+
+	tracker.NotifyTransfer(transfer.Risk{Connection: re5, Buffer: 3*time.Minute + 20*time.Second, Tight: true})
+	tracker.NotifyTransfer(transfer.Risk{Connection: re5, Buffer: -time.Minute, Missed: true, Alternative: &rb26})
+	tracker.NotifyTransfer(transfer.Risk{Connection: re5, Buffer: -time.Minute, Missed: true})
+	assert.Equal(t, []notification{
+		{"Transfer", "Transfer to <b>RE 5 → Koblenz Hbf 15:04</b> now 3 min — tight", helper.ConnectionNotification},
+		{"Transfer", "<b>RE 5 → Koblenz Hbf 15:04</b> will be missed — next: <b>RB 26 → Koblenz Hbf 15:24</b>", helper.ConnectionNotification},
+		{"Transfer", "<b>RE 5 → Koblenz Hbf 15:04</b> will be missed — no alternative found", helper.ConnectionNotification},
+	}, notifier.notifications)
+}
+
+func TestConnectivityForecast(t *testing.T) {
+	notifier := &recordingNotifier{}
+	tracker := NewTracker(notifier)
+	engine := events.NewEngine()
+	status := types.Status{Connectivity: types.Connectivity{CurrentState: "HIGH", NextState: "HIGH"}}
+	feed := func(current, next string, in time.Duration) {
+		status.Connectivity = types.Connectivity{CurrentState: current, NextState: next, NextIn: in}
+		for _, event := range engine.Feed(status, types.Trip{}) {
+			tracker.Handle(event)
+		}
+	}
+	feed("HIGH", "HIGH", 0)
+	notifier.notifications = nil
+
+	feed("HIGH", "WEAK", 2*time.Minute)
+	feed("HIGH", "WEAK", time.Minute)
+	feed("WEAK", "HIGH", 8*time.Minute)
+	feed("HIGH", "NO_INTERNET", 90*time.Second)
+	feed("NO_INTERNET", "WEAK", 5*time.Minute)
+	feed("WEAK", "NO_INFO", 5*time.Minute)
+	assert.Equal(t, []notification{
+		{"Internet", "Internet will be weak in 2 min", helper.ConnectivityNotification},
+		{"Internet", "Internet weak for ~8 min", helper.ConnectivityNotification},
+		{"Internet", "No internet in 2 min", helper.ConnectivityNotification},
+		{"Internet", "No internet for ~5 min", helper.ConnectivityNotification},
+	}, notifier.notifications)
+}
+
+func TestUnknownTrain(t *testing.T) {
+	notifier := &recordingNotifier{}
+	tracker := NewTracker(notifier)
+	engine := events.NewEngine()
+	for _, event := range engine.Feed(types.Status{Train: types.Train{Id: "gpsd"}, Speed: 30}, nil) {
+		tracker.Handle(event)
+	}
+	assert.Empty(t, notifier.notifications)
+}
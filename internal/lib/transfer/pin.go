@@ -0,0 +1,157 @@
+package transfer
+
+import (
+	"sort"
+	"sync"
+	"time"
+	"trk/internal/lib/types"
+)
+
+// Risk is due when the transfer buffer to the pinned connection falls below Threshold
+type Risk struct {
+	Stop       types.Stop
+	Connection types.Connection
+	Threshold  time.Duration
+	Buffer     time.Duration
+	// Tight is set if the buffer is shorter than the minimum transfer time
+	Tight bool
+	// Missed is set if the buffer is negative, the connection has been cancelled or
+	// it has dropped out of the list of connections
+	Missed bool
+	// Alternative is the next reachable connection to the same destination or of the same line, if Missed is set
+	Alternative *types.Connection
+}
+
+// Pin follows one connection at the destination and reports the risk of missing it
+type Pin struct {
+	Thresholds []time.Duration
+
+	mu     sync.Mutex
+	stopId string
+	key    string
+	// connection is the pinned connection as last seen, to find an alternative once it is gone
+	connection types.Connection
+	fired      map[time.Duration]bool
+	missed     bool
+}
+
+// NewPin creates a pin reporting when the transfer buffer falls below each of the thresholds
+func NewPin(thresholds []time.Duration) *Pin {
+	sorted := append([]time.Duration(nil), thresholds...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] > sorted[j]
+	})
+	return &Pin{
+		Thresholds: sorted,
+		fired:      make(map[time.Duration]bool),
+	}
+}
+
+// Set pins the connection at the stop with the id stopId
+func (p *Pin) Set(stopId string, connection types.Connection) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopId = stopId
+	p.key = key(connection)
+	p.connection = connection
+	p.fired = make(map[time.Duration]bool)
+	p.missed = false
+}
+
+// Clear removes the pinned connection
+func (p *Pin) Clear() {
+	p.Set("", types.Connection{})
+}
+
+// StopId returns the id of the stop of the pinned connection, empty if nothing is pinned
+func (p *Pin) StopId() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stopId
+}
+
+// IsPinned returns true if connection at the stop with the id stopId is the pinned one
+func (p *Pin) IsPinned(stopId string, connection types.Connection) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stopId != "" && p.stopId == stopId && p.key == key(connection)
+}
+
+// Find returns the pinned connection out of the connections at stop
+func (p *Pin) Find(stop types.Stop, connections []types.Connection) (types.Connection, bool) {
+	for _, connection := range connections {
+		if p.IsPinned(stop.Id, connection) {
+			return connection, true
+		}
+	}
+	return types.Connection{}, false
+}
+
+// Check computes the buffer between our arrival at stop and the departure of the pinned connection
+// and returns the risk which is due. Every threshold is reported once, missing the connection as well.
+// The portals drop departed and cancelled trains from the list, so a pinned connection missing from
+// a non-empty list counts as missed.
+func (p *Pin) Check(stop types.Stop, connections []types.Connection, minTransfer time.Duration) (Risk, bool) {
+	connection, found := p.Find(stop, connections)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !found {
+		if p.stopId == "" || p.stopId != stop.Id || len(connections) == 0 {
+			return Risk{}, false
+		}
+		connection = p.connection
+	}
+	p.connection = connection
+	buffer := Buffer(stop.ArrivalTime, connection)
+	risk := Risk{
+		Stop:       stop,
+		Connection: connection,
+		Buffer:     buffer,
+		Tight:      buffer < minTransfer,
+		Missed:     !found || buffer < 0 || connection.Cancelled || stop.Cancelled,
+	}
+	if risk.Missed {
+		if p.missed {
+			return Risk{}, false
+		}
+		p.missed = true
+		risk.Alternative = alternative(stop, connection, connections, minTransfer)
+		return risk, true
+	}
+
+	due := -1
+	for i, threshold := range p.Thresholds {
+		if buffer <= threshold && !p.fired[threshold] {
+			due = i
+		}
+	}
+	if due == -1 {
+		return Risk{}, false
+	}
+	for _, threshold := range p.Thresholds[:due+1] {
+		p.fired[threshold] = true
+	}
+	risk.Threshold = p.Thresholds[due]
+	return risk, true
+}
+
+// alternative returns the first reachable connection after missed to the same destination or of the same line
+func alternative(stop types.Stop, missed types.Connection, connections []types.Connection, minTransfer time.Duration) *types.Connection {
+	var best *types.Connection
+	for _, connection := range connections {
+		if key(connection) == key(missed) || connection.Cancelled {
+			continue
+		}
+		if connection.Destination != missed.Destination && connection.Train.DisplayName != missed.Train.DisplayName {
+			continue
+		}
+		if Buffer(stop.ArrivalTime, connection) < minTransfer {
+			continue
+		}
+		if best == nil || connection.DepartureTime.Before(best.DepartureTime) {
+			c := connection
+			best = &c
+		}
+	}
+	return best
+}
@@ -0,0 +1,123 @@
+package transfer
+
+import (
+	"testing"
+	"time"
+	"trk/internal/lib/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPinThresholds(t *testing.T) {
+	pin := NewPin([]time.Duration{3 * time.Minute, 5 * time.Minute})
+	connections := []types.Connection{connection("RE 5", 8*time.Minute)}
+	pin.Set("8000261", connections[0])
+
+	_, ok := pin.Check(stopWithDelay(0), connections, 4*time.Minute)
+	assert.False(t, ok)
+
+	risk, ok := pin.Check(stopWithDelay(3*time.Minute), connections, 4*time.Minute)
+	if assert.True(t, ok) {
+		assert.Equal(t, 5*time.Minute, risk.Threshold)
+		assert.Equal(t, 5*time.Minute, risk.Buffer)
+		assert.False(t, risk.Tight)
+		assert.False(t, risk.Missed)
+	}
+	_, ok = pin.Check(stopWithDelay(3*time.Minute), connections, 4*time.Minute)
+	assert.False(t, ok)
+
+	risk, ok = pin.Check(stopWithDelay(5*time.Minute), connections, 4*time.Minute)
+	if assert.True(t, ok) {
+		assert.Equal(t, 3*time.Minute, risk.Threshold)
+		assert.True(t, risk.Tight)
+	}
+}
+
+func TestPinReportsOnlyTheMostUrgentThreshold(t *testing.T) {
+	pin := NewPin([]time.Duration{3 * time.Minute, 5 * time.Minute})
+	connections := []types.Connection{connection("RE 5", 8*time.Minute)}
+	pin.Set("8000261", connections[0])
+
+	risk, ok := pin.Check(stopWithDelay(6*time.Minute), connections, 4*time.Minute)
+	if assert.True(t, ok) {
+		assert.Equal(t, 3*time.Minute, risk.Threshold)
+	}
+	_, ok = pin.Check(stopWithDelay(6*time.Minute), connections, 4*time.Minute)
+	assert.False(t, ok)
+}
+
+func TestPinSuggestsAlternative(t *testing.T) {
+	pin := NewPin([]time.Duration{3 * time.Minute})
+	re5 := connection("RE 5", 6*time.Minute)
+	re5.Destination = "Koblenz Hbf"
+	ice := connection("ICE 27", 10*time.Minute)
+	ice.Destination = "Wien Hbf"
+	rb := connection("RB 26", 20*time.Minute)
+	rb.Destination = "Koblenz Hbf"
+	laterRe5 := connection("RE 5", 66*time.Minute)
+	laterRe5.Destination = "Koblenz Hbf"
+	connections := []types.Connection{re5, ice, laterRe5, rb}
+	pin.Set("8000261", re5)
+
+	risk, ok := pin.Check(stopWithDelay(7*time.Minute), connections, 4*time.Minute)
+	if assert.True(t, ok) {
+		assert.True(t, risk.Missed)
+		assert.Equal(t, -time.Minute, risk.Buffer)
+		if assert.NotNil(t, risk.Alternative) {
+			assert.Equal(t, "RB 26", risk.Alternative.Train.DisplayName)
+		}
+	}
+	_, ok = pin.Check(stopWithDelay(8*time.Minute), connections, 4*time.Minute)
+	assert.False(t, ok)
+}
+
+func TestPinCancelledConnection(t *testing.T) {
+	pin := NewPin(nil)
+	connections := []types.Connection{connection("RE 5", 30*time.Minute)}
+	pin.Set("8000261", connections[0])
+	connections[0].Cancelled = true
+
+	risk, ok := pin.Check(stopWithDelay(0), connections, 4*time.Minute)
+	if assert.True(t, ok) {
+		assert.True(t, risk.Missed)
+		assert.Nil(t, risk.Alternative)
+	}
+}
+
+func TestPinConnectionDroppedFromList(t *testing.T) {
+	pin := NewPin(nil)
+	re5 := connection("RE 5", 6*time.Minute)
+	re5.Destination = "Koblenz Hbf"
+	rb := connection("RB 26", 20*time.Minute)
+	rb.Destination = "Koblenz Hbf"
+	pin.Set("8000261", re5)
+
+	_, ok := pin.Check(stopWithDelay(0), []types.Connection{re5, rb}, 4*time.Minute)
+	assert.False(t, ok)
+	_, ok = pin.Check(stopWithDelay(0), nil, 4*time.Minute)
+	assert.False(t, ok)
+
+	risk, ok := pin.Check(stopWithDelay(0), []types.Connection{rb}, 4*time.Minute)
+	if assert.True(t, ok) {
+		assert.True(t, risk.Missed)
+		assert.Equal(t, "RE 5", risk.Connection.Train.DisplayName)
+		if assert.NotNil(t, risk.Alternative) {
+			assert.Equal(t, "RB 26", risk.Alternative.Train.DisplayName)
+		}
+	}
+	_, ok = pin.Check(stopWithDelay(0), []types.Connection{rb}, 4*time.Minute)
+	assert.False(t, ok)
+}
+
+func TestPinOtherStop(t *testing.T) {
+	pin := NewPin([]time.Duration{5 * time.Minute})
+	connections := []types.Connection{connection("RE 5", 2*time.Minute)}
+	pin.Set("8000284", connections[0])
+	_, ok := pin.Check(stopWithDelay(0), connections, 4*time.Minute)
+	assert.False(t, ok)
+	assert.True(t, pin.IsPinned("8000284", connections[0]))
+
+	pin.Clear()
+	assert.Equal(t, "", pin.StopId())
+	assert.False(t, pin.IsPinned("8000284", connections[0]))
+}
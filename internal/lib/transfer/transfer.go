@@ -0,0 +1,65 @@
+// Package transfer decides which onward connections can still be reached.
+package transfer
+
+import (
+	"sync"
+	"time"
+	"trk/internal/lib/types"
+)
+
+// Buffer returns the time left for changing trains when arriving at arrival
+func Buffer(arrival time.Time, connection types.Connection) time.Duration {
+	return connection.DepartureTime.Sub(arrival)
+}
+
+// Monitor checks the connections at a stop against our arrival there and remembers
+// which of them were reachable, so every connection is reported only once when it is missed
+type Monitor struct {
+	// MinTransfer is the time needed to change trains
+	MinTransfer time.Duration
+
+	mu        sync.Mutex
+	stopId    string
+	reachable map[string]bool
+}
+
+func NewMonitor(minTransfer time.Duration) *Monitor {
+	return &Monitor{
+		MinTransfer: minTransfer,
+		reachable:   make(map[string]bool),
+	}
+}
+
+// Reachable returns true if the connection departs at least MinTransfer after the arrival at stop
+func (m *Monitor) Reachable(stop types.Stop, connection types.Connection) bool {
+	if connection.Cancelled || stop.Cancelled {
+		return false
+	}
+	return Buffer(stop.ArrivalTime, connection) >= m.MinTransfer
+}
+
+// Update checks the connections at stop and returns those which were reachable at the
+// previous update and are not anymore. Updating another stop forgets the previous one.
+func (m *Monitor) Update(stop types.Stop, connections []types.Connection) []types.Connection {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if stop.Id != m.stopId {
+		m.stopId = stop.Id
+		m.reachable = make(map[string]bool)
+	}
+	var missed []types.Connection
+	for _, connection := range connections {
+		k := key(connection)
+		reachable := m.Reachable(stop, connection)
+		if was, known := m.reachable[k]; known && was && !reachable {
+			missed = append(missed, connection)
+		}
+		m.reachable[k] = reachable
+	}
+	return missed
+}
+
+// key identifies a connection independent of its delay
+func key(connection types.Connection) string {
+	return connection.Train.DisplayName + "@" + connection.ScheduledDepartureTime.Format(time.RFC3339)
+}
@@ -0,0 +1,84 @@
+package transfer
+
+import (
+	"testing"
+	"time"
+	"trk/internal/lib/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var start = time.Date(2023, 2, 25, 13, 0, 0, 0, time.UTC)
+
+func stopWithDelay(delay time.Duration) types.Stop {
+	return types.Stop{
+		Id:                   "8000261",
+		Name:                 "München Hbf",
+		ScheduledArrivalTime: start,
+		ArrivalTime:          start.Add(delay),
+	}
+}
+
+func connection(name string, departure time.Duration) types.Connection {
+	return types.Connection{
+		Train:                  types.Train{DisplayName: name},
+		ScheduledDepartureTime: start.Add(departure),
+		DepartureTime:          start.Add(departure),
+	}
+}
+
+func TestReachable(t *testing.T) {
+	m := NewMonitor(4 * time.Minute)
+	stop := stopWithDelay(0)
+	assert.True(t, m.Reachable(stop, connection("RE 1", 4*time.Minute)))
+	assert.False(t, m.Reachable(stop, connection("RE 1", 3*time.Minute)))
+
+	cancelled := connection("RE 1", 10*time.Minute)
+	cancelled.Cancelled = true
+	assert.False(t, m.Reachable(stop, cancelled))
+
+	delayed := connection("RE 1", 3*time.Minute)
+	delayed.DepartureTime = delayed.DepartureTime.Add(2 * time.Minute)
+	assert.True(t, m.Reachable(stop, delayed))
+	assert.Equal(t, 5*time.Minute, Buffer(stop.ArrivalTime, delayed))
+}
+
+func TestUpdateReportsMissedConnectionsOnce(t *testing.T) {
+	m := NewMonitor(4 * time.Minute)
+	connections := []types.Connection{
+		connection("RE 1", 6*time.Minute),
+		connection("S 8", 13*time.Minute),
+	}
+
+	assert.Empty(t, m.Update(stopWithDelay(0), connections))
+	assert.Empty(t, m.Update(stopWithDelay(2*time.Minute), connections))
+
+	missed := m.Update(stopWithDelay(4*time.Minute), connections)
+	if assert.Len(t, missed, 1) {
+		assert.Equal(t, "RE 1", missed[0].Train.DisplayName)
+	}
+	assert.Empty(t, m.Update(stopWithDelay(5*time.Minute), connections))
+
+	// the connection waits for us
+	connections[0].DepartureTime = connections[0].DepartureTime.Add(5 * time.Minute)
+	assert.Empty(t, m.Update(stopWithDelay(5*time.Minute), connections))
+	connections[0].DepartureTime = connections[0].ScheduledDepartureTime
+	assert.Len(t, m.Update(stopWithDelay(5*time.Minute), connections), 1)
+}
+
+func TestUpdateForgetsPreviousStop(t *testing.T) {
+	m := NewMonitor(4 * time.Minute)
+	connections := []types.Connection{connection("RE 1", 6*time.Minute)}
+	assert.Empty(t, m.Update(stopWithDelay(0), connections))
+
+	other := stopWithDelay(4 * time.Minute)
+	other.Id = "8000284"
+	assert.Empty(t, m.Update(other, connections))
+}
+
+func TestUnreachableFromTheStartIsNotReported(t *testing.T) {
+	m := NewMonitor(4 * time.Minute)
+	connections := []types.Connection{connection("RE 1", 2*time.Minute)}
+	assert.Empty(t, m.Update(stopWithDelay(0), connections))
+	assert.Empty(t, m.Update(stopWithDelay(time.Minute), connections))
+}
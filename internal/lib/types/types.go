@@ -1,6 +1,9 @@
 package types
 
-import "time"
+import (
+	"math"
+	"time"
+)
 
 type Location struct {
 	Longitude float64
@@ -8,21 +11,96 @@ type Location struct {
 }
 
 type Stop struct {
-	Id          string
-	Name        string
-	Location    Location
-	ArrivalTime time.Time
-	Passed      bool
-	Track       string
+	Id       string
+	Name     string
+	Location Location
+	// ArrivalTime and DepartureTime are the actual times, the scheduled ones if there is no forecast
+	ArrivalTime            time.Time
+	ScheduledArrivalTime   time.Time
+	DepartureTime          time.Time
+	ScheduledDepartureTime time.Time
+	Passed                 bool
+	Cancelled              bool
+	Track                  string
+	// ScheduledTrack is the track according to the timetable, Track is the actual one
+	ScheduledTrack string
+	DelayReasons   []DelayReason
+}
+
+type DelayReason struct {
+	Code string
+	Text string
+}
+
+// ArrivalDelay returns the difference between the actual and the scheduled arrival
+func (s Stop) ArrivalDelay() time.Duration {
+	if s.ArrivalTime.IsZero() || s.ScheduledArrivalTime.IsZero() {
+		return 0
+	}
+	return s.ArrivalTime.Sub(s.ScheduledArrivalTime)
+}
+
+// DepartureDelay returns the difference between the actual and the scheduled departure
+func (s Stop) DepartureDelay() time.Duration {
+	if s.DepartureTime.IsZero() || s.ScheduledDepartureTime.IsZero() {
+		return 0
+	}
+	return s.DepartureTime.Sub(s.ScheduledDepartureTime)
+}
+
+// Delay returns the arrival delay, or the departure delay for the first stop
+func (s Stop) Delay() time.Duration {
+	if s.ScheduledArrivalTime.IsZero() {
+		return s.DepartureDelay()
+	}
+	return s.ArrivalDelay()
+}
+
+// DwellTime returns how long the train stays at the stop, 0 for the first and the last stop
+func (s Stop) DwellTime() time.Duration {
+	if s.ArrivalTime.IsZero() || s.DepartureTime.IsZero() {
+		return 0
+	}
+	return s.DepartureTime.Sub(s.ArrivalTime)
+}
+
+// TrackChanged returns true if the stop's track differs from the timetable
+func (s Stop) TrackChanged() bool {
+	return s.ScheduledTrack != "" && s.Track != "" && s.Track != s.ScheduledTrack
 }
 
 type Status struct {
 	Train     Train
-	Speed     int64 // in m/s
+	Speed     float64 // in m/s
 	Timestamp time.Time
 	Delay     time.Duration
 	Location  Location
 	NextStop  Stop
+	// GPSLost is set if the position is not current, e.g. inside a tunnel
+	GPSLost      bool
+	Connectivity Connectivity
+}
+
+// SpeedKmh returns the speed in km/h rounded to a whole number, for display
+func (s Status) SpeedKmh() int64 {
+	return int64(math.Round(s.Speed * 3.6))
+}
+
+// States of the train's internet connection as reported by the portal
+const (
+	ConnectivityHigh     = "HIGH"
+	ConnectivityWeak     = "WEAK"
+	ConnectivityUnstable = "UNSTABLE"
+	ConnectivityNone     = "NO_INTERNET"
+	ConnectivityUnknown  = "NO_INFO"
+)
+
+// Connectivity is the state of the train's internet connection and its forecast
+type Connectivity struct {
+	CurrentState string
+	NextState    string
+	// NextIn is the time until NextState is reached
+	NextIn time.Duration
 }
 
 type Train struct {
@@ -33,6 +111,28 @@ type Train struct {
 	Line          string
 	Series        string
 	SeriesDisplay string
+	// Operator is the vagonweb.cz country code of the railway operating the train, e.g. CD
+	Operator string
+}
+
+// Connection is an onward train departing from a stop of the trip
+type Connection struct {
+	Train       Train
+	Destination string
+	// DepartureTime is the actual departure, the scheduled one if there is no forecast
+	DepartureTime          time.Time
+	ScheduledDepartureTime time.Time
+	Track                  string
+	ScheduledTrack         string
+	Cancelled              bool
+}
+
+// Delay returns the difference between the actual and the scheduled departure
+func (c Connection) Delay() time.Duration {
+	if c.DepartureTime.IsZero() || c.ScheduledDepartureTime.IsZero() {
+		return 0
+	}
+	return c.DepartureTime.Sub(c.ScheduledDepartureTime)
 }
 
 type Trip []Stop